@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+vendor 回调的通用签名, vendor 没有自定义验签方式时使用
+
+	X-Callback-Timestamp: unix 秒
+	X-Callback-Signature: hex(HMAC-SHA256(secret, timestamp + "\n" + body))
+*/
+const (
+	CallbackTimestampHeader = "X-Callback-Timestamp"
+	CallbackSignatureHeader = "X-Callback-Signature"
+	// 时间戳允许的偏差, 超出视为重放
+	callbackMaxSkew = 5 * time.Minute
+)
+
+// ErrInvalidSignature 回调签名校验失败
+var ErrInvalidSignature = errors.New("invalid callback signature")
+
+// CallbackPayload 通用回调内容
+type CallbackPayload struct {
+	Domain  string `json:"domain"`
+	Status  string `json:"status"` // configuring, online, offline, failed, deleting
+	CNAME   string `json:"cname,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// SignCallback 计算回调签名
+func SignCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallbackSignature 校验通用回调签名及时间戳
+func VerifyCallbackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	timestamp := header.Get(CallbackTimestampHeader)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > callbackMaxSkew || skew < -callbackMaxSkew {
+		return ErrInvalidSignature
+	}
+	expected := SignCallback(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(CallbackSignatureHeader))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -1,5 +1,13 @@
 package client
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrDNSNotImplemented DNS 服务商尚未对接, 不会创建或删除解析记录
+var ErrDNSNotImplemented = errors.New("dns provider not integrated yet")
+
 type DNSClient struct {
 }
 
@@ -7,3 +15,15 @@ type DNSClient struct {
 func NewDNSClient() *DNSClient {
 	return &DNSClient{}
 }
+
+// CreateRecord 在托管的zone下添加解析记录, 返回记录ID
+func (dc *DNSClient) CreateRecord(ctx context.Context, zone, subDomain, recordType, value string) (string, error) {
+	// 待对接 dnspod
+	return "", ErrDNSNotImplemented
+}
+
+// DeleteRecord 删除解析记录, 记录不存在时视为成功
+func (dc *DNSClient) DeleteRecord(ctx context.Context, zone, recordID string) error {
+	// 待对接 dnspod
+	return ErrDNSNotImplemented
+}
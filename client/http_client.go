@@ -67,6 +67,13 @@ func WithTransport(transport *http.Transport) HTTPClientOption {
 	}
 }
 
+// WithCheckRedirect 自定义重定向策略, 返回 http.ErrUseLastResponse 时不跟随重定向
+func WithCheckRedirect(fn func(req *http.Request, via []*http.Request) error) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.client.CheckRedirect = fn
+	}
+}
+
 // Request 发送 HTTP 请求
 func (c *HTTPClient) Request(ctx context.Context, method, url string, body interface{}, headers map[string]string) (*http.Response, error) {
 	return c.RequestWithLogger(ctx, method, url, body, headers, nil)
@@ -1,18 +1,136 @@
 package client
 
-// 万能的 Mock  Client
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"centralHub/model"
+)
+
+// 万能的 Mock  Client, 域名保存在内存中
 type MockClient struct {
+	name    string
+	mu      sync.Mutex
+	domains map[string]*model.VendorDomain
+}
+
+// NewMockClient name 为空时使用 "mock-vendor"
+func NewMockClient(name string) *MockClient {
+	if name == "" {
+		name = "mock-vendor"
+	}
+	return &MockClient{
+		name:    name,
+		domains: make(map[string]*model.VendorDomain),
+	}
+}
+
+func (mc *MockClient) Name() string {
+	return mc.name
+}
+
+func (mc *MockClient) CreateDomain(ctx context.Context, req model.VendorDomainRequest) (*model.VendorDomain, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	name := req.Domain.Name
+	if _, ok := mc.domains[name]; ok {
+		return nil, NewVendorError(mc.Name(), "CreateDomain", ErrKindConflict, "", "domain already exists", nil)
+	}
+	now := time.Now()
+	d := &model.VendorDomain{
+		Vendor:    mc.Name(),
+		Domain:    name,
+		CNAME:     name + ".mock-cdn.com",
+		Status:    model.VendorDomainOnline,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	mc.domains[name] = d
+	cp := *d
+	return &cp, nil
+}
+
+func (mc *MockClient) GetDomain(ctx context.Context, domain string) (*model.VendorDomain, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	d, ok := mc.domains[domain]
+	if !ok {
+		return nil, NewVendorError(mc.Name(), "GetDomain", ErrKindNotFound, "", "", nil)
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (mc *MockClient) UpdateDomainConfig(ctx context.Context, req model.VendorDomainRequest) error {
+	return mc.update("UpdateDomainConfig", req.Domain.Name, "")
+}
+
+func (mc *MockClient) DeleteDomain(ctx context.Context, domain string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if _, ok := mc.domains[domain]; !ok {
+		return NewVendorError(mc.Name(), "DeleteDomain", ErrKindNotFound, "", "", nil)
+	}
+	delete(mc.domains, domain)
+	return nil
+}
+
+func (mc *MockClient) EnableDomain(ctx context.Context, domain string) error {
+	return mc.update("EnableDomain", domain, model.VendorDomainOnline)
 }
 
-func NewMockClient() *MockClient {
-	return &MockClient{}
+func (mc *MockClient) DisableDomain(ctx context.Context, domain string) error {
+	return mc.update("DisableDomain", domain, model.VendorDomainOffline)
 }
 
-func (mc *MockClient) GetVendorName(params ...interface{}) string {
-	return "vendor-67890"
+func (mc *MockClient) Purge(ctx context.Context, req model.PurgeRequest) (*model.VendorTaskResult, error) {
+	if _, err := mc.GetDomain(ctx, req.Domain); err != nil {
+		return nil, err
+	}
+	return &model.VendorTaskResult{Vendor: mc.Name(), TaskID: uuid.New().String()}, nil
 }
 
-func (mc *MockClient) CreateDomain(params ...interface{}) error {
-	// 模拟创建域名的逻辑
+func (mc *MockClient) Prefetch(ctx context.Context, req model.PrefetchRequest) (*model.VendorTaskResult, error) {
+	if _, err := mc.GetDomain(ctx, req.Domain); err != nil {
+		return nil, err
+	}
+	return &model.VendorTaskResult{Vendor: mc.Name(), TaskID: uuid.New().String()}, nil
+}
+
+func (mc *MockClient) GetCNAME(ctx context.Context, domain string) (string, error) {
+	d, err := mc.GetDomain(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+	return d.CNAME, nil
+}
+
+func (mc *MockClient) GetStatus(ctx context.Context, domain string) (model.VendorDomainStatus, error) {
+	d, err := mc.GetDomain(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+	return d.Status, nil
+}
+
+// update 修改状态, status 为空时只更新时间
+func (mc *MockClient) update(op, domain string, status model.VendorDomainStatus) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	d, ok := mc.domains[domain]
+	if !ok {
+		return NewVendorError(mc.Name(), op, ErrKindNotFound, "", "", nil)
+	}
+	if status != "" {
+		d.Status = status
+	}
+	d.UpdatedAt = time.Now()
 	return nil
 }
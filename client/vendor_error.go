@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKind vendor 错误类型, workflow 按类型决定重试、忽略或失败
+type ErrKind string
+
+const (
+	ErrKindNotFound      ErrKind = "not_found"
+	ErrKindConflict      ErrKind = "conflict"       // 已存在, 或被其他账号占用
+	ErrKindQuotaExceeded ErrKind = "quota_exceeded" // 域名数等配额不足
+	ErrKindRateLimited   ErrKind = "rate_limited"
+	ErrKindUnavailable   ErrKind = "vendor_unavailable" // 网络错误、5xx
+	ErrKindInvalid       ErrKind = "invalid_request"
+	ErrKindUnknown       ErrKind = "unknown"
+)
+
+// VendorError vendor 调用错误
+type VendorError struct {
+	Vendor  string
+	Op      string
+	Kind    ErrKind
+	Code    string // vendor 原始错误码
+	Message string
+	Err     error
+}
+
+func (e *VendorError) Error() string {
+	msg := fmt.Sprintf("vendor %s %s: %s", e.Vendor, e.Op, e.Kind)
+	if e.Code != "" {
+		msg += " [" + e.Code + "]"
+	}
+	if e.Message != "" {
+		msg += " " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *VendorError) Unwrap() error {
+	return e.Err
+}
+
+// NewVendorError 创建 vendor 错误
+func NewVendorError(vendor, op string, kind ErrKind, code, message string, err error) *VendorError {
+	return &VendorError{Vendor: vendor, Op: op, Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// KindOf 返回错误类型, 非 VendorError 返回 ErrKindUnknown
+func KindOf(err error) ErrKind {
+	var ve *VendorError
+	if errors.As(err, &ve) {
+		return ve.Kind
+	}
+	return ErrKindUnknown
+}
+
+// IsKind 判断错误是否为指定类型
+func IsKind(err error, kind ErrKind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// IsRetryable 限流、vendor 不可用以及无法识别的错误可重试
+func IsRetryable(err error) bool {
+	switch KindOf(err) {
+	case ErrKindRateLimited, ErrKindUnavailable, ErrKindUnknown:
+		return true
+	}
+	return false
+}
@@ -1,17 +1,508 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
 	volc "github.com/volcengine/volc-sdk-golang/service/cdn"
+
+	"centralHub/config"
+	"centralHub/model"
 )
 
+// VolcClient 火山引擎 CDN 适配器
+// SDK 的封装方法不支持 context, 这里直接通过 base.Client 发送请求, 复用 SDK 的签名和请求/响应结构
 type VolcClient struct {
+	name     string
 	instance *volc.CDN
 }
 
-func NewVolcClient() *VolcClient {
+// NewVolcClient 创建火山引擎 CDN 客户端, name 为空时使用 "volcengine"
+// Endpoint/Scheme 可指向本地的 fake server, 用于离线调试
+func NewVolcClient(name string, cfg config.VolcengineConfig) *VolcClient {
+	if name == "" {
+		name = "volcengine"
+	}
 	ins := volc.NewInstance()
+	ins.Client.SetAccessKey(cfg.AccessKey)
+	ins.Client.SetSecretKey(cfg.SecretKey)
+	if cfg.Region != "" {
+		ins.SetRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		ins.SetHost(cfg.Endpoint)
+	}
+	if cfg.Scheme != "" {
+		ins.SetSchema(cfg.Scheme)
+	}
+	if cfg.Timeout > 0 {
+		ins.Client.SetTimeout(time.Duration(cfg.Timeout) * time.Second)
+	}
 
 	return &VolcClient{
+		name:     name,
 		instance: ins,
 	}
 }
+
+func (vc *VolcClient) Name() string {
+	return vc.name
+}
+
+func (vc *VolcClient) CreateDomain(ctx context.Context, req model.VendorDomainRequest) (*model.VendorDomain, error) {
+	obj := req.Domain
+	in := &volc.AddCdnDomainRequest{
+		Domain:         obj.Name,
+		Origin:         volcOrigins(obj.Origins),
+		OriginProtocol: volc.GetStrPtr(volcOriginProtocol(obj)),
+		ServiceType:    volc.GetStrPtr(volcServiceType(obj)),
+	}
+	if obj.OriginHost != "" {
+		in.OriginHost = volc.GetStrPtr(obj.OriginHost)
+	}
+	in.Cache = volcCacheRules(obj.Cache)
+	in.CacheKey = volcCacheKey(obj.Cache)
+	in.HTTPS = volcHTTPS(obj.HTTPS)
+	in.IpAccessRule = volcIPAccessRule(obj.Access)
+	in.RefererAccessRule = volcRefererAccessRule(obj.Access)
+	in.SignedUrlAuth = volcSignedURLAuth(obj.Access)
+	in.FollowRedirect = volcFollowRedirect(obj.Redirect)
+	if err := vc.call(ctx, "CreateDomain", "AddCdnDomain", in, &volc.AddCdnDomainResponse{}); err != nil {
+		return nil, err
+	}
+	// AddCdnDomain 只返回资源ID, cname 需要再查询一次
+	return vc.GetDomain(ctx, obj.Name)
+}
+
+func (vc *VolcClient) GetDomain(ctx context.Context, domain string) (*model.VendorDomain, error) {
+	out := &volc.DescribeCdnConfigResponse{}
+	if err := vc.call(ctx, "GetDomain", "DescribeCdnConfig", &volc.DescribeCdnConfigRequest{Domain: domain}, out); err != nil {
+		return nil, err
+	}
+	dc := out.Result.DomainConfig
+	d := &model.VendorDomain{
+		Vendor: vc.Name(),
+		Domain: domain,
+		CNAME:  derefStr(dc.Cname),
+		Status: volcDomainStatus(derefStr(dc.Status)),
+	}
+	if dc.CreateTime != nil {
+		d.CreatedAt = time.Unix(*dc.CreateTime, 0)
+	}
+	if dc.UpdateTime != nil {
+		d.UpdatedAt = time.Unix(*dc.UpdateTime, 0)
+	}
+	return d, nil
+}
+
+func (vc *VolcClient) UpdateDomainConfig(ctx context.Context, req model.VendorDomainRequest) error {
+	obj := req.Domain
+	in := &volc.UpdateCdnConfigRequest{
+		Domain:         volc.GetStrPtr(obj.Name),
+		OriginProtocol: volc.GetStrPtr(volcOriginProtocol(obj)),
+	}
+	if len(obj.Origins) > 0 {
+		in.Origin = volcOrigins(obj.Origins)
+	}
+	if obj.OriginHost != "" {
+		in.OriginHost = volc.GetStrPtr(obj.OriginHost)
+	}
+	in.Cache = volcCacheRules(obj.Cache)
+	in.CacheKey = volcCacheKey(obj.Cache)
+	in.HTTPS = volcHTTPS(obj.HTTPS)
+	in.IpAccessRule = volcIPAccessRule(obj.Access)
+	in.RefererAccessRule = volcRefererAccessRule(obj.Access)
+	in.SignedUrlAuth = volcSignedURLAuth(obj.Access)
+	in.FollowRedirect = volcFollowRedirect(obj.Redirect)
+	return vc.call(ctx, "UpdateDomainConfig", "UpdateCdnConfig", in, &volc.UpdateCdnConfigResponse{})
+}
+
+// DeleteDomain 火山引擎只能删除已停用的域名, 先停用再删除
+func (vc *VolcClient) DeleteDomain(ctx context.Context, domain string) error {
+	if err := vc.DisableDomain(ctx, domain); err != nil {
+		// 已停用时停用接口报参数/状态错误, 交由删除接口判断; 其他错误直接返回
+		if kind := KindOf(err); kind != ErrKindInvalid && kind != ErrKindConflict {
+			return err
+		}
+	}
+	return vc.call(ctx, "DeleteDomain", "DeleteCdnDomain", &volc.DeleteCdnDomainRequest{Domain: domain}, &volc.DeleteCdnDomainResponse{})
+}
+
+func (vc *VolcClient) EnableDomain(ctx context.Context, domain string) error {
+	return vc.call(ctx, "EnableDomain", "StartCdnDomain", &volc.StartCdnDomainRequest{Domain: domain}, &volc.StartCdnDomainResponse{})
+}
+
+func (vc *VolcClient) DisableDomain(ctx context.Context, domain string) error {
+	return vc.call(ctx, "DisableDomain", "StopCdnDomain", &volc.StopCdnDomainRequest{Domain: domain}, &volc.StopCdnDomainResponse{})
+}
+
+// Purge 文件和目录分别提交刷新任务, 多个任务ID以逗号分隔
+func (vc *VolcClient) Purge(ctx context.Context, req model.PurgeRequest) (*model.VendorTaskResult, error) {
+	if len(req.URLs) == 0 && len(req.Dirs) == 0 {
+		return nil, NewVendorError(vc.Name(), "Purge", ErrKindInvalid, "", "no urls or dirs to purge", nil)
+	}
+	var taskIDs []string
+	for _, batch := range []struct {
+		typ  string
+		urls []string
+	}{{"file", req.URLs}, {"dir", req.Dirs}} {
+		if len(batch.urls) == 0 {
+			continue
+		}
+		out := &volc.SubmitRefreshTaskResponse{}
+		in := &volc.SubmitRefreshTaskRequest{
+			Type: volc.GetStrPtr(batch.typ),
+			Urls: strings.Join(batch.urls, "\n"),
+		}
+		if err := vc.call(ctx, "Purge", "SubmitRefreshTask", in, out); err != nil {
+			return nil, err
+		}
+		taskIDs = append(taskIDs, out.Result.TaskID)
+	}
+	return &model.VendorTaskResult{Vendor: vc.Name(), TaskID: strings.Join(taskIDs, ",")}, nil
+}
+
+func (vc *VolcClient) Prefetch(ctx context.Context, req model.PrefetchRequest) (*model.VendorTaskResult, error) {
+	if len(req.URLs) == 0 {
+		return nil, NewVendorError(vc.Name(), "Prefetch", ErrKindInvalid, "", "no urls to prefetch", nil)
+	}
+	out := &volc.SubmitPreloadTaskResponse{}
+	in := &volc.SubmitPreloadTaskRequest{Urls: strings.Join(req.URLs, "\n")}
+	if err := vc.call(ctx, "Prefetch", "SubmitPreloadTask", in, out); err != nil {
+		return nil, err
+	}
+	return &model.VendorTaskResult{Vendor: vc.Name(), TaskID: out.Result.TaskID}, nil
+}
+
+func (vc *VolcClient) GetCNAME(ctx context.Context, domain string) (string, error) {
+	d, err := vc.GetDomain(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+	return d.CNAME, nil
+}
+
+func (vc *VolcClient) GetStatus(ctx context.Context, domain string) (model.VendorDomainStatus, error) {
+	d, err := vc.GetDomain(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+	return d.Status, nil
+}
+
+// volcEnvelope 响应公共部分
+type volcEnvelope struct {
+	ResponseMetadata *volc.ResponseMetadata `json:",omitempty"`
+}
+
+// call 发送请求并解析响应, 错误统一转换为 VendorError
+func (vc *VolcClient) call(ctx context.Context, op, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return NewVendorError(vc.Name(), op, ErrKindInvalid, "", "marshal request failed", err)
+	}
+	data, status, err := vc.instance.Client.CtxJson(ctx, action, url.Values{}, string(body))
+
+	// 非 2xx 时响应体中通常也带有错误码, 优先按错误码归类
+	var env volcEnvelope
+	_ = json.Unmarshal(data, &env)
+	if meta := env.ResponseMetadata; meta != nil && meta.Error != nil {
+		return NewVendorError(vc.Name(), op, volcErrKind(meta.Error.Code, status), meta.Error.Code, meta.Error.Message, nil)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return NewVendorError(vc.Name(), op, ErrKindUnavailable, "", "", ctxErr)
+		}
+		return NewVendorError(vc.Name(), op, httpErrKind(status), strconv.Itoa(status), "", err)
+	}
+	if env.ResponseMetadata == nil {
+		return NewVendorError(vc.Name(), op, ErrKindUnknown, "", "response meta is not found", nil)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return NewVendorError(vc.Name(), op, ErrKindUnknown, "", "unmarshal response failed", err)
+	}
+	return nil
+}
+
+// volcErrKind 按火山引擎错误码归类, 错误码无法识别时按 HTTP 状态码
+func volcErrKind(code string, status int) ErrKind {
+	switch {
+	case strings.Contains(code, "FlowLimit"), strings.Contains(code, "RequestLimit"),
+		strings.Contains(code, "Throttl"), strings.Contains(code, "TooManyRequests"):
+		return ErrKindRateLimited
+	case strings.Contains(code, "NotFound"), strings.Contains(code, "NotExist"):
+		return ErrKindNotFound
+	case strings.Contains(code, "AlreadyExist"), strings.Contains(code, "Duplicate"),
+		strings.Contains(code, "Conflict"), strings.Contains(code, "Occupied"):
+		return ErrKindConflict
+	case strings.Contains(code, "Quota"), strings.Contains(code, "LimitExceeded"):
+		return ErrKindQuotaExceeded
+	case strings.Contains(code, "InternalError"), strings.Contains(code, "ServiceUnavailable"),
+		strings.Contains(code, "Timeout"):
+		return ErrKindUnavailable
+	case strings.HasPrefix(code, "Invalid"), strings.HasPrefix(code, "Missing"),
+		strings.Contains(code, "Unsupported"), strings.Contains(code, "AccessDenied"),
+		strings.Contains(code, "Signature"):
+		return ErrKindInvalid
+	}
+	return httpErrKind(status)
+}
+
+// httpErrKind 按 HTTP 状态码归类, status 为 0 表示请求未发出或无响应
+func httpErrKind(status int) ErrKind {
+	switch {
+	case status == http.StatusNotFound:
+		return ErrKindNotFound
+	case status == http.StatusConflict:
+		return ErrKindConflict
+	case status == http.StatusTooManyRequests:
+		return ErrKindRateLimited
+	case status == 0, status >= http.StatusInternalServerError:
+		return ErrKindUnavailable
+	case status >= http.StatusBadRequest:
+		return ErrKindInvalid
+	}
+	return ErrKindUnknown
+}
+
+// volcOrigins 源站转换为一条无条件的回源规则
+func volcOrigins(origins []model.Origin) []volc.OriginRule {
+	if len(origins) == 0 {
+		return nil
+	}
+	lines := make([]volc.OriginLine, 0, len(origins))
+	for _, o := range origins {
+		instanceType := "domain"
+		if net.ParseIP(o.Address) != nil {
+			instanceType = "ip"
+		}
+		originType := model.OriginPrimary
+		if o.Type != "" {
+			originType = o.Type
+		}
+		httpPort, httpsPort, weight := o.HTTPPort, o.HTTPSPort, o.Weight
+		if httpPort == 0 {
+			httpPort = 80
+		}
+		if httpsPort == 0 {
+			httpsPort = 443
+		}
+		if weight == 0 {
+			weight = 1
+		}
+		lines = append(lines, volc.OriginLine{
+			Address:      volc.GetStrPtr(o.Address),
+			InstanceType: volc.GetStrPtr(instanceType),
+			OriginType:   volc.GetStrPtr(string(originType)),
+			HttpPort:     volc.GetStrPtr(strconv.Itoa(httpPort)),
+			HttpsPort:    volc.GetStrPtr(strconv.Itoa(httpsPort)),
+			Weight:       volc.GetStrPtr(strconv.Itoa(weight)),
+		})
+	}
+	return []volc.OriginRule{{OriginAction: &volc.OriginAction{OriginLines: lines}}}
+}
+
+func volcOriginProtocol(obj model.XLDomain) string {
+	if obj.OriginProtocol != "" {
+		return obj.OriginProtocol
+	}
+	return "http"
+}
+
+func volcServiceType(obj model.XLDomain) string {
+	if obj.ServiceType != "" {
+		return obj.ServiceType
+	}
+	return "web"
+}
+
+// volcCacheRules 缓存规则转换, 未配置时使用火山引擎默认规则
+func volcCacheRules(cache *model.CacheConfig) []volc.CacheControlRule {
+	if cache == nil {
+		return nil
+	}
+	var rules []volc.CacheControlRule
+	for _, r := range cache.Rules {
+		object, value := "path", r.Value
+		switch r.Type {
+		case model.CacheRuleAll:
+			value = "/*"
+		case model.CacheRuleSuffix:
+			object, value = "filetype", strings.ReplaceAll(r.Value, ",", ";")
+		case model.CacheRuleDirectory:
+			object = "directory"
+		}
+		action := "cache"
+		if r.TTL == 0 {
+			action = "no_cache"
+		}
+		rules = append(rules, volc.CacheControlRule{
+			CacheAction: &volc.CacheAction{
+				Action: volc.GetStrPtr(action),
+				Ttl:    int64Ptr(r.TTL),
+			},
+			Condition: volcURLCondition(object, value),
+		})
+	}
+	return rules
+}
+
+// volcCacheKey 缓存键是否包含查询参数, 未配置缓存时不修改
+func volcCacheKey(cache *model.CacheConfig) []volc.CacheKeyRule {
+	if cache == nil {
+		return nil
+	}
+	action := "include"
+	if cache.IgnoreQuery {
+		action = "exclude"
+	}
+	return []volc.CacheKeyRule{{
+		CacheKeyAction: &volc.CacheKeyAction{CacheKeyComponents: []volc.CacheKeyComponent{{
+			Action:    volc.GetStrPtr(action),
+			Object:    volc.GetStrPtr("queryString"),
+			Subobject: volc.GetStrPtr("*"),
+		}}},
+		Condition: volcURLCondition("path", "/*"),
+	}}
+}
+
+// volcURLCondition 按 url 匹配的单条件
+func volcURLCondition(object, value string) *volc.Condition {
+	return &volc.Condition{
+		ConditionRule: []volc.ConditionRule{{
+			Type:     volc.GetStrPtr("url"),
+			Object:   volc.GetStrPtr(object),
+			Operator: volc.GetStrPtr("match"),
+			Value:    volc.GetStrPtr(value),
+		}},
+		Connective: volc.GetStrPtr("OR"),
+	}
+}
+
+// volcHTTPS https 配置转换, 证书优先使用已托管的 cert_id
+func volcHTTPS(h *model.HTTPSConfig) *volc.HTTPS {
+	if h == nil {
+		return nil
+	}
+	out := &volc.HTTPS{Switch: boolPtr(h.Enabled)}
+	if !h.Enabled {
+		return out
+	}
+	cert := &volc.CertInfo{}
+	if h.CertID != "" {
+		cert.CertId = volc.GetStrPtr(h.CertID)
+	} else {
+		cert.Certificate = &volc.Certificate{
+			Certificate: volc.GetStrPtr(h.Certificate),
+			PrivateKey:  volc.GetStrPtr(h.PrivateKey),
+		}
+	}
+	out.CertInfo = cert
+	out.HTTP2 = boolPtr(h.HTTP2)
+	out.ForcedRedirect = &volc.ForcedRedirect{
+		EnableForcedRedirect: boolPtr(h.ForceRedirect),
+		StatusCode:           volc.GetStrPtr("301"),
+	}
+	out.TlsVersion = h.TLSVersions
+	return out
+}
+
+func volcIPAccessRule(a *model.AccessConfig) *volc.IpAccessRule {
+	if a == nil || len(a.IPBlacklist)+len(a.IPWhitelist) == 0 {
+		return nil
+	}
+	if len(a.IPWhitelist) > 0 {
+		return &volc.IpAccessRule{Switch: boolPtr(true), RuleType: volc.GetStrPtr("allow"), Ip: a.IPWhitelist}
+	}
+	return &volc.IpAccessRule{Switch: boolPtr(true), RuleType: volc.GetStrPtr("deny"), Ip: a.IPBlacklist}
+}
+
+func volcRefererAccessRule(a *model.AccessConfig) *volc.RefererAccessRule {
+	if a == nil || a.Referer == nil {
+		return nil
+	}
+	ruleType := "deny"
+	if a.Referer.Type == model.ListWhite {
+		ruleType = "allow"
+	}
+	return &volc.RefererAccessRule{
+		Switch:       boolPtr(true),
+		RuleType:     volc.GetStrPtr(ruleType),
+		AllowEmpty:   boolPtr(a.Referer.AllowEmpty),
+		ReferersType: &volc.ReferersType{CommonType: &volc.CommonReferType{Referers: a.Referer.Values}},
+	}
+}
+
+// volcSignedURLAuth URL 鉴权, 配置了访问控制但没有鉴权时关闭
+func volcSignedURLAuth(a *model.AccessConfig) *volc.SignedUrlAuth {
+	if a == nil {
+		return nil
+	}
+	if a.Auth == nil {
+		return &volc.SignedUrlAuth{Switch: boolPtr(false)}
+	}
+	action := &volc.SignedUrlAuthAction{
+		URLAuthType:     volc.GetStrPtr(strings.ReplaceAll(string(a.Auth.Type), "_", "")), // type_a -> typea
+		AuthAlgorithm:   volc.GetStrPtr("md5"),
+		MasterSecretKey: volc.GetStrPtr(a.Auth.PrimaryKey),
+		Duration:        int64Ptr(a.Auth.TTL),
+	}
+	if a.Auth.BackupKey != "" {
+		action.BackupSecretKey = volc.GetStrPtr(a.Auth.BackupKey)
+	}
+	if a.Auth.ParamName != "" {
+		action.SignName = volc.GetStrPtr(a.Auth.ParamName)
+	}
+	return &volc.SignedUrlAuth{
+		Switch: boolPtr(true),
+		SignedUrlAuthRules: []volc.SignedUrlAuthRule{{
+			Condition:           volcURLCondition("path", "/*"),
+			SignedUrlAuthAction: action,
+		}},
+	}
+}
+
+// volcFollowRedirect 回源跟随 302, 火山引擎不支持设置跟随次数
+func volcFollowRedirect(r *model.RedirectConfig) *bool {
+	if r == nil {
+		return nil
+	}
+	return boolPtr(r.FollowOrigin)
+}
+
+// volcDomainStatus 火山引擎域名状态转换
+func volcDomainStatus(status string) model.VendorDomainStatus {
+	switch status {
+	case "online":
+		return model.VendorDomainOnline
+	case "offline":
+		return model.VendorDomainOffline
+	case "configure_failed":
+		return model.VendorDomainFailed
+	case "deleting", "removing":
+		return model.VendorDomainDeleting
+	}
+	// configuring, stopping, starting 等中间状态
+	return model.VendorDomainConfiguring
+}
+
+func derefStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	volc "github.com/volcengine/volc-sdk-golang/service/cdn"
+
+	"centralHub/config"
+	"centralHub/model"
+)
+
+const (
+	testVolcAK     = "AKTEST"
+	testVolcSK     = "SKTEST"
+	testVolcRegion = "cn-north-1"
+)
+
+// volcFakeServer 本地 fake server, 校验签名后按 Action 返回 handler 的结果
+func volcFakeServer(t *testing.T, handler func(action string, body []byte) (int, interface{})) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifyVolcSignature(r, body); err != "" {
+			t.Errorf("bad signature: %s", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		status, resp := handler(r.URL.Query().Get("Action"), body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if resp != nil {
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// verifyVolcSignature 按 HMAC-SHA256 V4 规则独立重算签名, 返回不一致的原因
+func verifyVolcSignature(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "HMAC-SHA256 ") {
+		return "missing authorization: " + auth
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "HMAC-SHA256 "), ", ") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			fields[k] = v
+		}
+	}
+	cred := strings.Split(fields["Credential"], "/")
+	if len(cred) != 5 || cred[0] != testVolcAK || cred[2] != testVolcRegion || cred[3] != "CDN" || cred[4] != "request" {
+		return "unexpected credential: " + fields["Credential"]
+	}
+
+	bodyHash := sha256Hex(body)
+	if r.Header.Get("X-Content-Sha256") != bodyHash {
+		return "body hash mismatch"
+	}
+	var headers strings.Builder
+	for _, h := range strings.Split(fields["SignedHeaders"], ";") {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	query := strings.ReplaceAll(r.URL.Query().Encode(), "+", "%20")
+	canonical := strings.Join([]string{r.Method, r.URL.Path, query, headers.String(), fields["SignedHeaders"], bodyHash}, "\n")
+
+	xdate := r.Header.Get("X-Date")
+	scope := strings.Join(cred[1:], "/")
+	toSign := strings.Join([]string{"HMAC-SHA256", xdate, scope, sha256Hex([]byte(canonical))}, "\n")
+	key := []byte(testVolcSK)
+	for _, s := range []string{cred[1], cred[2], cred[3], "request"} {
+		key = hmacSHA256(key, s)
+	}
+	if want := hex.EncodeToString(hmacSHA256(key, toSign)); fields["Signature"] != want {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+func newTestVolcClient(srv *httptest.Server) *VolcClient {
+	return NewVolcClient("volc-test", config.VolcengineConfig{
+		AccessKey: testVolcAK,
+		SecretKey: testVolcSK,
+		Region:    testVolcRegion,
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		Scheme:    "http",
+		Timeout:   5,
+	})
+}
+
+func volcErrorBody(code string) map[string]interface{} {
+	return map[string]interface{}{
+		"ResponseMetadata": map[string]interface{}{
+			"RequestId": "req-1",
+			"Action":    "Test",
+			"Error":     map[string]string{"Code": code, "Message": code + " message"},
+		},
+	}
+}
+
+func TestVolcClientGetDomain(t *testing.T) {
+	var gotDomain string
+	srv := volcFakeServer(t, func(action string, body []byte) (int, interface{}) {
+		if action != "DescribeCdnConfig" {
+			t.Errorf("action = %q, want DescribeCdnConfig", action)
+		}
+		var in struct{ Domain string }
+		_ = json.Unmarshal(body, &in)
+		gotDomain = in.Domain
+		return http.StatusOK, map[string]interface{}{
+			"ResponseMetadata": map[string]string{"RequestId": "req-1", "Action": action},
+			"Result": map[string]interface{}{
+				"DomainConfig": map[string]interface{}{
+					"Domain": in.Domain,
+					"Cname":  in.Domain + ".volcgslb.com",
+					"Status": "online",
+				},
+			},
+		}
+	})
+
+	d, err := newTestVolcClient(srv).GetDomain(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatalf("GetDomain: %v", err)
+	}
+	if gotDomain != "www.example.com" {
+		t.Errorf("request domain = %q", gotDomain)
+	}
+	if d.Vendor != "volc-test" || d.CNAME != "www.example.com.volcgslb.com" || d.Status != model.VendorDomainOnline {
+		t.Errorf("unexpected domain: %+v", d)
+	}
+}
+
+func TestVolcClientErrorMapping(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   interface{}
+		want   ErrKind
+		code   string
+	}{
+		{"invalid code", http.StatusBadRequest, volcErrorBody("InvalidParameter"), ErrKindInvalid, "InvalidParameter"},
+		{"not found code", http.StatusNotFound, volcErrorBody("ResourceNotFound.Domain"), ErrKindNotFound, "ResourceNotFound.Domain"},
+		{"already exists code", http.StatusOK, volcErrorBody("AlreadyExist.Domain"), ErrKindConflict, "AlreadyExist.Domain"},
+		{"flow limit code", http.StatusOK, volcErrorBody("FlowLimitExceeded"), ErrKindRateLimited, "FlowLimitExceeded"},
+		{"unknown code falls back to status", http.StatusConflict, volcErrorBody("SomethingOdd"), ErrKindConflict, "SomethingOdd"},
+		{"bare 500", http.StatusInternalServerError, nil, ErrKindUnavailable, "500"},
+		{"bare 429", http.StatusTooManyRequests, nil, ErrKindRateLimited, "429"},
+		{"missing response meta", http.StatusOK, map[string]interface{}{}, ErrKindUnknown, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := volcFakeServer(t, func(string, []byte) (int, interface{}) {
+				return tc.status, tc.body
+			})
+
+			_, err := newTestVolcClient(srv).GetDomain(context.Background(), "www.example.com")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if kind := KindOf(err); kind != tc.want {
+				t.Errorf("kind = %s, want %s (err: %v)", kind, tc.want, err)
+			}
+			var ve *VendorError
+			if !errors.As(err, &ve) || ve.Code != tc.code || ve.Vendor != "volc-test" || ve.Op != "GetDomain" {
+				t.Errorf("unexpected vendor error: %#v", err)
+			}
+		})
+	}
+}
+
+func TestVolcClientUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	c := newTestVolcClient(srv)
+	srv.Close()
+
+	_, err := c.GetDomain(context.Background(), "www.example.com")
+	if kind := KindOf(err); kind != ErrKindUnavailable {
+		t.Errorf("kind = %s, want %s (err: %v)", kind, ErrKindUnavailable, err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("unreachable endpoint should be retryable: %v", err)
+	}
+}
+
+func TestVolcClientCreateDomainMapsAuthAndCacheKey(t *testing.T) {
+	var added struct {
+		CacheKey      []volc.CacheKeyRule
+		SignedUrlAuth *volc.SignedUrlAuth
+	}
+	srv := volcFakeServer(t, func(action string, body []byte) (int, interface{}) {
+		meta := map[string]string{"RequestId": "req-1", "Action": action}
+		if action == "AddCdnDomain" {
+			_ = json.Unmarshal(body, &added)
+			return http.StatusOK, map[string]interface{}{"ResponseMetadata": meta}
+		}
+		return http.StatusOK, map[string]interface{}{
+			"ResponseMetadata": meta,
+			"Result":           map[string]interface{}{"DomainConfig": map[string]interface{}{"Cname": "x.volcgslb.com", "Status": "configuring"}},
+		}
+	})
+
+	obj := model.XLDomain{
+		Name:  "www.example.com",
+		Cache: &model.CacheConfig{IgnoreQuery: true},
+		Access: &model.AccessConfig{Auth: &model.URLAuthConfig{
+			Type: model.URLAuthTypeA, PrimaryKey: "primary1", TTL: 1800, ParamName: "sign",
+		}},
+	}
+	if _, err := newTestVolcClient(srv).CreateDomain(context.Background(), model.VendorDomainRequest{Domain: obj}); err != nil {
+		t.Fatalf("CreateDomain: %v", err)
+	}
+
+	if len(added.CacheKey) != 1 || len(added.CacheKey[0].CacheKeyAction.CacheKeyComponents) != 1 ||
+		*added.CacheKey[0].CacheKeyAction.CacheKeyComponents[0].Action != "exclude" {
+		t.Errorf("unexpected cache key: %+v", added.CacheKey)
+	}
+	auth := added.SignedUrlAuth
+	if auth == nil || !*auth.Switch || len(auth.SignedUrlAuthRules) != 1 {
+		t.Fatalf("unexpected signed url auth: %+v", auth)
+	}
+	action := auth.SignedUrlAuthRules[0].SignedUrlAuthAction
+	if *action.URLAuthType != "typea" || *action.MasterSecretKey != "primary1" || *action.Duration != 1800 || *action.SignName != "sign" {
+		t.Errorf("unexpected signed url auth action: %+v", action)
+	}
+}
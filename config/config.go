@@ -3,7 +3,10 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config represents the application configuration
@@ -12,6 +15,12 @@ type Config struct {
 	Database DatabaseConfig `json:"database"`
 	Logger   LoggerConfig   `json:"logger"`
 	External ExternalConfig `json:"external"`
+	Workflow WorkflowConfig `json:"workflow"`
+	Vendors  []VendorConfig `json:"vendors"`
+	// rules for choosing vendors per domain
+	VendorPolicy VendorPolicyConfig `json:"vendor_policy"`
+	CNAME        CNAMEConfig        `json:"cname"`
+	Ownership    OwnershipConfig    `json:"ownership"`
 }
 
 // ServerConfig represents server-related configuration
@@ -53,6 +62,88 @@ type VolcengineConfig struct {
 	AccessKey string `json:"access_key"`
 	SecretKey string `json:"secret_key"`
 	Region    string `json:"region"`
+	Endpoint  string `json:"endpoint"` // API host, defaults to cdn.volcengineapi.com
+	Scheme    string `json:"scheme"`   // http or https, defaults to https
+	Timeout   int    `json:"timeout"`  // request timeout in seconds
+}
+
+// WorkflowConfig represents workflow task engine configuration
+type WorkflowConfig struct {
+	Workers      int `json:"workers"`       // number of concurrent task workers
+	QueueSize    int `json:"queue_size"`    // in-memory task queue capacity
+	ScanInterval int `json:"scan_interval"` // seconds between pending task rescans
+	LeaseTTL     int `json:"lease_ttl"`     // seconds a task lease stays valid without heartbeat
+	// default policy for duplicate submissions: return_existing, queue, supersede
+	DuplicatePolicy string `json:"duplicate_policy"`
+	// seconds to wait for vendor domains to come online before failing the task
+	VendorWaitTimeout int `json:"vendor_wait_timeout"`
+	// initial seconds between vendor status polls, doubled up to one minute
+	VendorPollInterval int `json:"vendor_poll_interval"`
+}
+
+// VendorConfig represents a CDN vendor instance
+type VendorConfig struct {
+	Name      string `json:"name"`    // unique vendor name, recorded on tasks and vendor domains
+	Type      string `json:"type"`    // provider type registered in the vendor registry, defaults to name
+	Enabled   bool   `json:"enabled"` // disabled vendors are not selected for new domains
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	Region    string `json:"region"`
+	Endpoint  string `json:"endpoint"` // optional API host override
+	Scheme    string `json:"scheme"`   // optional API scheme override
+	Timeout   int    `json:"timeout"`  // request timeout in seconds
+	Weight    int    `json:"weight"`   // selection weight, higher is preferred (cheaper), defaults to 1
+	// supported features: https, http2, quic, ipv6, wildcard
+	Capabilities []string `json:"capabilities"`
+	// service regions covered: mainland, overseas, global; empty means all
+	Regions []string `json:"regions"`
+	// secret for verifying POST /callbacks/{name}; callbacks are rejected when empty
+	CallbackSecret string `json:"callback_secret"`
+}
+
+// VendorPolicyConfig represents vendor selection rules
+type VendorPolicyConfig struct {
+	MaxVendors int                `json:"max_vendors"` // vendors per domain, defaults to 1
+	Tenants    []TenantVendorRule `json:"tenants"`     // per owner allow/deny lists
+	// successes required among selected vendors: all, any, or a number N (at least N); defaults to all
+	Quorum string `json:"quorum"`
+}
+
+// TenantVendorRule restricts the vendors used for an owner
+type TenantVendorRule struct {
+	Owner string   `json:"owner"`
+	Allow []string `json:"allow"` // only these vendors, empty means all
+	Deny  []string `json:"deny"`
+}
+
+// CNAMEConfig represents CNAME allocation settings
+type CNAMEConfig struct {
+	Suffix string `json:"suffix"` // DNS zone the CNAMEs are created under, e.g. xldns.com
+	// per owner zone overrides, owner -> suffix
+	TenantSuffixes map[string]string `json:"tenant_suffixes"`
+}
+
+// OwnershipConfig represents domain ownership verification settings
+type OwnershipConfig struct {
+	// HMAC key for challenge tokens, required in release mode; a random key is used otherwise
+	Secret   string `json:"secret"`
+	TokenTTL int    `json:"token_ttl"` // seconds a challenge token stays valid, defaults to 86400
+	// checks allowed per challenge before it fails, defaults to 10
+	MaxAttempts int `json:"max_attempts"`
+	// seconds unverified challenges are kept after expiry, defaults to 604800
+	Retention int `json:"retention"`
+	// DNS servers (host or host:port) queried for TXT records; empty uses the system resolver
+	Resolvers      []string `json:"resolvers"`
+	ResolveTimeout int      `json:"resolve_timeout"` // seconds per resolver query, defaults to 5
+	HTTPTimeout    int      `json:"http_timeout"`    // seconds per verification file request, defaults to 10
+	// zone the cname verification targets are generated under, defaults to verify.<cname suffix>
+	CNAMEZone string `json:"cname_zone"`
+	// seconds between re-checks of verified challenges, defaults to 86400
+	RecheckInterval int `json:"recheck_interval"`
+	// seconds a proof may stay missing before the domains are suspended, defaults to 259200
+	RecheckGrace int `json:"recheck_grace"`
+	// optional URL that ownership events (lost, restored, revoked) are POSTed to as JSON
+	NotifyURL string `json:"notify_url"`
 }
 
 var GlobalConfig *Config
@@ -82,6 +173,44 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Default returns the configuration used when no config file exists,
+// with the remaining defaults filled in by the same validation as Load
+func Default() (*Config, error) {
+	cfg := Config{
+		Server: ServerConfig{
+			Port:    "8080",
+			Mode:    "debug",
+			Timeout: 30,
+		},
+		Database: DatabaseConfig{
+			MongoDB: MongoDBConfig{
+				URI:      "mongodb://localhost:27017",
+				Database: "centralhub",
+				Timeout:  10,
+			},
+		},
+		Logger: LoggerConfig{
+			Level:      "info",
+			Output:     "stdout",
+			FilePath:   "logs/app.log",
+			MaxSize:    100,
+			MaxBackups: 3,
+			MaxAge:     7,
+		},
+		External: ExternalConfig{
+			Volcengine: VolcengineConfig{Region: "cn-beijing"},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default configuration: %w", err)
+	}
+
+	// Set global config
+	GlobalConfig = &cfg
+
+	return &cfg, nil
+}
+
 // validate validates the configuration
 func (c *Config) validate() error {
 	// Validate server config
@@ -108,9 +237,184 @@ func (c *Config) validate() error {
 		c.Logger.Output = "stdout" // default output
 	}
 
+	// Validate workflow config
+	if c.Workflow.Workers <= 0 {
+		c.Workflow.Workers = 4 // default workers
+	}
+	if c.Workflow.QueueSize <= 0 {
+		c.Workflow.QueueSize = 100 // default queue size
+	}
+	if c.Workflow.ScanInterval <= 0 {
+		c.Workflow.ScanInterval = 30 // default scan interval
+	}
+	if c.Workflow.LeaseTTL <= 0 {
+		c.Workflow.LeaseTTL = 30 // default lease ttl
+	}
+	if c.Workflow.VendorWaitTimeout <= 0 {
+		c.Workflow.VendorWaitTimeout = 1800 // default vendor wait timeout
+	}
+	if c.Workflow.VendorPollInterval <= 0 {
+		c.Workflow.VendorPollInterval = 5 // default vendor poll interval
+	}
+	switch c.Workflow.DuplicatePolicy {
+	case "":
+		c.Workflow.DuplicatePolicy = "return_existing" // default duplicate policy
+	case "return_existing", "queue", "supersede":
+	default:
+		return fmt.Errorf("invalid workflow duplicate_policy: %s", c.Workflow.DuplicatePolicy)
+	}
+
+	// Validate vendor config
+	if len(c.Vendors) == 0 {
+		c.Vendors = c.defaultVendors()
+	}
+	names := make(map[string]bool)
+	for i := range c.Vendors {
+		v := &c.Vendors[i]
+		if v.Name == "" {
+			return fmt.Errorf("vendor name is required")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("duplicate vendor name: %s", v.Name)
+		}
+		names[v.Name] = true
+		if v.Type == "" {
+			v.Type = v.Name // default type
+		}
+		if v.Weight < 0 {
+			return fmt.Errorf("invalid vendor %s weight: %d", v.Name, v.Weight)
+		}
+		if v.Weight == 0 {
+			v.Weight = 1 // default weight
+		}
+	}
+
+	// Validate vendor policy config
+	if c.VendorPolicy.MaxVendors <= 0 {
+		c.VendorPolicy.MaxVendors = 1 // default max vendors
+	}
+	switch c.VendorPolicy.Quorum {
+	case "":
+		c.VendorPolicy.Quorum = "all" // default quorum
+	case "all", "any":
+	default:
+		n, err := strconv.Atoi(c.VendorPolicy.Quorum)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid vendor_policy quorum: %s", c.VendorPolicy.Quorum)
+		}
+		if n > c.VendorPolicy.MaxVendors {
+			return fmt.Errorf("vendor_policy quorum %d exceeds max_vendors %d", n, c.VendorPolicy.MaxVendors)
+		}
+	}
+	for _, rule := range c.VendorPolicy.Tenants {
+		if rule.Owner == "" {
+			return fmt.Errorf("vendor_policy tenant owner is required")
+		}
+		for _, name := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if !names[name] {
+				return fmt.Errorf("vendor_policy tenant %s: unknown vendor %s", rule.Owner, name)
+			}
+		}
+	}
+
+	// Validate cname config
+	if c.CNAME.Suffix == "" {
+		c.CNAME.Suffix = "xldns.com" // default suffix
+	}
+	if !isValidZone(c.CNAME.Suffix) {
+		return fmt.Errorf("invalid cname suffix: %s", c.CNAME.Suffix)
+	}
+	for owner, suffix := range c.CNAME.TenantSuffixes {
+		if !isValidZone(suffix) {
+			return fmt.Errorf("invalid cname suffix for tenant %s: %s", owner, suffix)
+		}
+	}
+
+	// Validate ownership config
+	if c.Ownership.Secret == "" && c.IsProduction() {
+		return fmt.Errorf("ownership secret is required in release mode")
+	}
+	if c.Ownership.TokenTTL <= 0 {
+		c.Ownership.TokenTTL = 86400 // default token ttl
+	}
+	if c.Ownership.MaxAttempts <= 0 {
+		c.Ownership.MaxAttempts = 10 // default max attempts
+	}
+	if c.Ownership.Retention <= 0 {
+		c.Ownership.Retention = 604800 // default challenge retention
+	}
+	if c.Ownership.ResolveTimeout <= 0 {
+		c.Ownership.ResolveTimeout = 5 // default resolve timeout
+	}
+	if c.Ownership.HTTPTimeout <= 0 {
+		c.Ownership.HTTPTimeout = 10 // default http timeout
+	}
+	if c.Ownership.CNAMEZone == "" {
+		c.Ownership.CNAMEZone = "verify." + c.CNAME.Suffix // default cname verification zone
+	}
+	if c.Ownership.RecheckInterval <= 0 {
+		c.Ownership.RecheckInterval = 86400 // default recheck interval
+	}
+	if c.Ownership.RecheckGrace <= 0 {
+		c.Ownership.RecheckGrace = 259200 // default recheck grace period
+	}
+	if u := c.Ownership.NotifyURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("invalid ownership notify_url: %s", u)
+	}
+	if !isValidZone(c.Ownership.CNAMEZone) {
+		return fmt.Errorf("invalid ownership cname_zone: %s", c.Ownership.CNAMEZone)
+	}
+	for i, r := range c.Ownership.Resolvers {
+		host, port, err := net.SplitHostPort(r)
+		if err != nil {
+			host, port = r, "53" // default dns port
+		}
+		if host == "" {
+			return fmt.Errorf("invalid ownership resolver: %s", r)
+		}
+		c.Ownership.Resolvers[i] = net.JoinHostPort(host, port)
+	}
+
 	return nil
 }
 
+// defaultVendors returns the vendors used when no vendors section is configured:
+// the mock vendor, plus Volcengine if legacy external credentials are set
+func (c *Config) defaultVendors() []VendorConfig {
+	vendors := []VendorConfig{{
+		Name: "mock-vendor", Type: "mock", Enabled: true, Weight: 1,
+		Capabilities: []string{"https", "http2", "quic", "ipv6", "wildcard"},
+	}}
+	if volc := c.External.Volcengine; volc.AccessKey != "" {
+		vendors = append(vendors, VendorConfig{
+			Name:      "volcengine",
+			Type:      "volcengine",
+			Enabled:   true,
+			AccessKey: volc.AccessKey,
+			SecretKey: volc.SecretKey,
+			Region:    volc.Region,
+			Endpoint:  volc.Endpoint,
+			Scheme:    volc.Scheme,
+			Timeout:   volc.Timeout,
+			Weight:    1,
+		})
+	}
+	return vendors
+}
+
+// isValidZone reports whether s is a dot separated host name without leading or trailing dots
+func isValidZone(s string) bool {
+	if s == "" || len(s) > 253 || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
 // GetServerAddress returns the server address
 func (c *Config) GetServerAddress() string {
 	return ":" + c.Server.Port
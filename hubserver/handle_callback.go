@@ -0,0 +1,53 @@
+package hubserver
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"centralHub/client"
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/workflow"
+)
+
+// 回调请求体上限
+const maxCallbackBodySize = 1 << 20
+
+// HandleVendorCallback POST /callbacks/:vendor vendor 推送域名状态变更
+// 验签通过后记录事件, 并立即推进等待该域名上线的任务
+func (hs *HubServer) HandleVendorCallback(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	vendor := c.Param("vendor")
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCallbackBodySize))
+	if err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+
+	event, err := hs.workflow.ReceiveVendorCallback(c.Request.Context(), vendor, c.Request.Header, body)
+	switch {
+	case errors.Is(err, workflow.ErrVendorNotFound):
+		c.JSON(404, model.NewErrorResponse(model.CodeNotFound, err.Error()))
+		return
+	case errors.Is(err, client.ErrInvalidSignature):
+		rlog.Warn().Str("vendor", vendor).Msg("Reject vendor callback with invalid signature")
+		c.JSON(401, model.NewErrorResponse(model.CodeUnauthorized, err.Error()))
+		return
+	case errors.Is(err, workflow.ErrInvalidCallback):
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	case err != nil:
+		rlog.Error().Err(err).Str("vendor", vendor).Msg("Failed to handle vendor callback")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	rlog.Info().Str("vendor", vendor).Str("domain", event.Domain).Str("status", string(event.Status)).Msg("Vendor callback received")
+
+	resp := model.NewSuccessResponse(event)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
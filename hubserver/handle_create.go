@@ -1,22 +1,22 @@
 package hubserver
 
 import (
+	"errors"
+
 	"centralHub/logger"
 	"centralHub/model"
+	"centralHub/workflow"
 
 	"github.com/gin-gonic/gin"
 )
 
-func (hs *HubServer) preCreateCheck() {
-	// 请求，任务检测( 防止重复提交？ 排队？ 不同请求？ 覆盖？)
+func (hs *HubServer) preCreateCheck(obj model.XLDomain) {
+	// 请求，任务检测: 由 workflow.SubmitTask 按幂等键及 duplicate_policy 处理
 
 	// 域名有效性检查(备案) ICP
 	// get ICP info from govt API
 
-	// 域名所有权检查(ownership)
-	//
-	domain := ""
-	hs.getOwnership(domain)
+	// 域名所有权检查(ownership): 由 workflow 在提交及执行创建任务时检查, 未验证时返回 ErrOwnershipNotVerified
 
 	// 域名检查
 
@@ -24,34 +24,83 @@ func (hs *HubServer) preCreateCheck() {
 
 func (hs *HubServer) HandleCreate(c *gin.Context) {
 
-	reqid, _ := c.Get("reqid")
-	rlog := logger.WithReqID(reqid.(string))
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
 
 	var reqObj model.AddDomainRequest
 	//parse form data
 	if err := c.ShouldBind(&reqObj); err != nil {
 		rlog.Error().Err(err).Msg("Failed to bind request data")
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
 		return
 	}
 
-	rlog.Info().Str("domain", reqObj.Domain.Name).Str("owner", reqObj.Domain.Owner).Msg("Start create domain task")
+	if !checkDomainConfig(c, reqid, &reqObj.Domain) {
+		return
+	}
+	rlog.Info().Str("domain", reqObj.Domain.Name).Str("type", string(reqObj.Domain.Type)).Str("owner", reqObj.Domain.Owner).Msg("Start create domain task")
 
-	hs.preCreateCheck()
-	// task pipeline
-	taskId := "okay"
-	//taskId := hs.workflow.PushTask()
-	hs.workflow.CreateDomain(c, reqObj.Domain)
+	if reqObj.DuplicatePolicy != "" && !reqObj.DuplicatePolicy.IsValid() {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, "invalid duplicate_policy: "+string(reqObj.DuplicatePolicy)))
+		return
+	}
+
+	hs.preCreateCheck(reqObj.Domain)
+	// task pipeline: build Cname, midsrc, provider CDN configure, double-check(test)
+	// 由 workflow worker 异步执行, 这里只返回任务ID
+	result, err := hs.workflow.SubmitTask(c.Request.Context(), model.TaskTypeCreateDomain, reqObj.Domain, workflow.SubmitOptions{
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+		Policy:         reqObj.DuplicatePolicy,
+		Actor:          operator(c, reqObj.Domain.Owner),
+		RequestID:      reqid,
+	})
+	if errors.Is(err, workflow.ErrIdempotencyKeyReused) || errors.Is(err, workflow.ErrDomainOverlap) ||
+		errors.Is(err, workflow.ErrInvalidDomainState) {
+		c.JSON(409, model.NewErrorResponse(model.CodeConflict, err.Error()))
+		return
+	}
+	if errors.Is(err, workflow.ErrOwnershipNotVerified) {
+		c.JSON(403, model.NewErrorResponse(model.CodeForbidden, err.Error()))
+		return
+	}
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed to submit create domain task")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	rlog.Info().Str("task_id", result.Task.ID).Str("outcome", result.Outcome).Msg("Create domain task submitted")
 
-	// build Cname  source Cname
-	// midsrc
-	// provider CDN configure
-	// double-check(test)
-	//
+	resp := model.NewSuccessResponse(model.CreateTaskResponse{
+		TaskID:  result.Task.ID,
+		Domain:  result.Task.Domain,
+		Status:  string(result.Task.State),
+		Outcome: result.Outcome,
+		Related: result.Related,
+	})
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
 
-	// json response todo
-	c.JSON(200, gin.H{"task_id": taskId})
-	// write http response , taskId
-	//
-	// error
+// checkDomainConfig 规范化并校验域名配置, 不合法时响应 400 及字段错误并返回 false
+func checkDomainConfig(c *gin.Context, reqid string, d *model.XLDomain) bool {
+	err := d.Normalize()
+	if err == nil {
+		err = d.Validate()
+	}
+	if err == nil {
+		return true
+	}
+	resp := model.NewErrorResponse(model.CodeBadRequest, err.Error())
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		resp.Data = verr.Errors
+	}
+	resp.TraceID = reqid
+	c.JSON(400, resp)
+	return false
 }
@@ -0,0 +1,186 @@
+package hubserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+	"centralHub/workflow"
+)
+
+// PATCH 请求体上限
+const maxPatchBodySize = 1 << 20
+
+// HandleListDomains GET /api/v1/domains 按 name/owner/status/type 分页查询域名
+//
+//	?name=&owner=&status=&type=&page=1&size=20, name 为包含匹配, status 为空时不返回已删除的域名
+func (hs *HubServer) HandleListDomains(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	type ReqObj struct {
+		Name   string `form:"name"`
+		Owner  string `form:"owner"`
+		Status string `form:"status"`
+		Type   string `form:"type"`
+	}
+	var reqObj ReqObj
+	if err := c.ShouldBindQuery(&reqObj); err != nil {
+		domainError(c, reqid, 400, model.CodeBadRequest, err)
+		return
+	}
+	status := model.DomainStatus(reqObj.Status)
+	if status != "" && !status.IsValid() {
+		domainError(c, reqid, 400, model.CodeBadRequest, fmt.Errorf("invalid status: %s", reqObj.Status))
+		return
+	}
+	page, size, err := parsePage(c)
+	if err != nil {
+		domainError(c, reqid, 400, model.CodeBadRequest, err)
+		return
+	}
+
+	domains, total, err := hs.workflow.ListDomains(c.Request.Context(), store.DomainFilter{
+		Name:   reqObj.Name,
+		Owner:  reqObj.Owner,
+		Status: status,
+		Type:   model.DomainType(reqObj.Type),
+	}, page, size)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed to list domains")
+		domainError(c, reqid, 500, model.CodeServerError, err)
+		return
+	}
+	items := make([]model.DomainResponse, 0, len(domains))
+	for _, d := range domains {
+		items = append(items, model.NewDomainResponse(d))
+	}
+	resp := model.NewPageResponse(items, total, page, size)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandleGetDomain GET /api/v1/domains/:id 查询域名、当前配置及最近的状态变更
+func (hs *HubServer) HandleGetDomain(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	id := c.Param("id")
+	domain, err := hs.workflow.GetDomain(c.Request.Context(), id)
+	if err != nil {
+		rlog.Error().Err(err).Str("domain_id", id).Msg("Failed to get domain")
+		domainError(c, reqid, 500, model.CodeServerError, err)
+		return
+	}
+	if domain == nil {
+		domainError(c, reqid, 404, model.CodeNotFound, workflow.ErrDomainNotFound)
+		return
+	}
+
+	data := model.NewDomainResponse(*domain)
+	data.StatusHistory = domain.StatusHistory
+	resp := model.NewSuccessResponse(data)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandlePatchDomain PATCH /api/v1/domains/:id 修改域名配置
+//
+//	请求体为 JSON Merge Patch, 如 {"cache": {"rules": [...]}, "redirect": null}
+//	提交 update_domain 任务推送到全部 vendor, 成功后生成新的配置版本
+func (hs *HubServer) HandlePatchDomain(c *gin.Context) {
+	reqid := c.GetString("reqid")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPatchBodySize))
+	if err != nil {
+		domainError(c, reqid, 400, model.CodeBadRequest, err)
+		return
+	}
+	result, err := hs.workflow.PatchDomain(c.Request.Context(), c.Param("id"), body, hs.domainTaskOptions(c, reqid))
+	hs.respondDomainTask(c, reqid, "patch", result, err)
+}
+
+// HandleDeleteDomain DELETE /api/v1/domains/:id 删除域名, 由 delete_domain 任务删除各 vendor 上的域名
+func (hs *HubServer) HandleDeleteDomain(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	result, err := hs.workflow.RemoveDomain(c.Request.Context(), c.Param("id"), hs.domainTaskOptions(c, reqid))
+	hs.respondDomainTask(c, reqid, "delete", result, err)
+}
+
+// HandleEnableDomain POST /api/v1/domains/:id/enable 在全部 vendor 上启用域名
+//
+//	因所有权失效停用(suspended)的域名需重新验证所有权后才能启用, 未验证时返回 403
+func (hs *HubServer) HandleEnableDomain(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	result, err := hs.workflow.SetDomainEnabled(c.Request.Context(), c.Param("id"), true, hs.domainTaskOptions(c, reqid))
+	hs.respondDomainTask(c, reqid, "enable", result, err)
+}
+
+// HandleDisableDomain POST /api/v1/domains/:id/disable 在全部 vendor 上停用域名
+func (hs *HubServer) HandleDisableDomain(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	result, err := hs.workflow.SetDomainEnabled(c.Request.Context(), c.Param("id"), false, hs.domainTaskOptions(c, reqid))
+	hs.respondDomainTask(c, reqid, "disable", result, err)
+}
+
+// domainTaskOptions 域名操作任务的提交选项
+func (hs *HubServer) domainTaskOptions(c *gin.Context, reqid string) workflow.SubmitOptions {
+	return workflow.SubmitOptions{
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+		Actor:          operator(c, ""),
+		RequestID:      reqid,
+	}
+}
+
+// respondDomainTask 返回域名操作提交的任务, 或按错误类型返回对应状态码
+func (hs *HubServer) respondDomainTask(c *gin.Context, reqid, op string, result *workflow.SubmitResult, err error) {
+	rlog := logger.WithReqID(reqid)
+	domainID := c.Param("id")
+
+	var verr *model.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		resp := model.NewErrorResponse(model.CodeBadRequest, err.Error())
+		resp.Data = verr.Errors
+		resp.TraceID = reqid
+		c.JSON(400, resp)
+		return
+	case errors.Is(err, workflow.ErrDomainNotFound), errors.Is(err, workflow.ErrRevisionNotFound):
+		domainError(c, reqid, 404, model.CodeNotFound, err)
+		return
+	case errors.Is(err, workflow.ErrInvalidDomainState), errors.Is(err, workflow.ErrIdempotencyKeyReused),
+		errors.Is(err, workflow.ErrDomainOverlap):
+		domainError(c, reqid, 409, model.CodeConflict, err)
+		return
+	case errors.Is(err, workflow.ErrOwnershipNotVerified):
+		domainError(c, reqid, 403, model.CodeForbidden, err)
+		return
+	case err != nil:
+		rlog.Error().Err(err).Str("domain_id", domainID).Str("op", op).Msg("Failed to submit domain task")
+		domainError(c, reqid, 500, model.CodeServerError, err)
+		return
+	}
+	rlog.Info().Str("domain_id", domainID).Str("op", op).Str("task_id", result.Task.ID).Str("outcome", result.Outcome).Msg("Domain task submitted")
+
+	resp := model.NewSuccessResponse(model.CreateTaskResponse{
+		TaskID:  result.Task.ID,
+		Domain:  result.Task.Domain,
+		Status:  string(result.Task.State),
+		Outcome: result.Outcome,
+		Related: result.Related,
+	})
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// domainError 返回带 trace_id 的错误响应
+func domainError(c *gin.Context, reqid string, status, code int, err error) {
+	resp := model.NewErrorResponse(code, err.Error())
+	resp.TraceID = reqid
+	c.JSON(status, resp)
+}
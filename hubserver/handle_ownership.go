@@ -1,12 +1,24 @@
 package hubserver
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/service"
+)
 
 /*
 	支持用户域名所有权检查的交互接口(ownership)
-	dns txt 记录
-		cname 记录
-	节点服务器file upload 验证
+	dns txt 记录: _centralhub-challenge.<domain>
+	cname 记录: centralhub-<hash>.<domain> -> <hash>.<cname_zone>
+	节点服务器file upload 验证: /.well-known/centralhub/<token>.txt
+	泛域名 *.example.com 无法在通配名上放置记录或文件, 按 example.com 验证
 */
 
 func (hs *HubServer) HandleOwnershipCheck(c *gin.Context) {
@@ -15,9 +27,13 @@ func (hs *HubServer) HandleOwnershipCheck(c *gin.Context) {
 	// 用户提交域名，验证形式，发起验证
 	// 响应对应验证类型的 值 和此次验证请求的任务ID
 
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
 	type ReqObj struct {
 		Domain     string `form:"domain" binding:"required"`
-		VerifyType string `form:"verify_type" binding:"required"` // dns | file
+		Owner      string `form:"owner" binding:"required"`
+		VerifyType string `form:"verify_type" binding:"required"` // dns | file | cname
 	}
 	var reqObj ReqObj
 	//parse form data
@@ -25,30 +41,47 @@ func (hs *HubServer) HandleOwnershipCheck(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	var value string
-	switch reqObj.VerifyType {
-	case "dns":
-		value = hs.makeTXTStr(reqObj.Domain)
-	case "file":
-		_, value = hs.makeFile(reqObj.Domain)
+	domain := model.XLDomain{Name: reqObj.Domain}
+	if err := domain.Normalize(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reqObj.Domain = domain.Name
+	method, ok := parseVerifyType(c, reqObj.VerifyType)
+	if !ok {
+		return
 	}
 
 	type RespObj struct {
-		Domain     string `json:"domain"`
-		VerifyType string `json:"verify_type"`
-		Value      string `json:"value"`
-		ReqID      string `json:"req_id"`
+		Domain      string `json:"domain"`
+		VerifyType  string `json:"verify_type"`
+		RecordName  string `json:"record_name,omitempty"` // dns: TXT 记录名; cname: 需添加的 cname 记录名
+		Path        string `json:"path,omitempty"`        // file: 文件路径, http 及 https 均可访问
+		Value       string `json:"value"`                 // TXT 记录值, 文件内容或 cname 目标
+		ReqID       string `json:"req_id"`
+		MaxAttempts int    `json:"max_attempts"`
+		ExpireAt    string `json:"expire_at"`
 	}
 
+	// 挑战保存为 pending, 验证时以 req_id 查找
+	challenge, err := hs.ownership.Issue(c.Request.Context(), domain.BaseName(), reqObj.Owner, method)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed to create ownership challenge")
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 	respObj := RespObj{
-		Domain:     reqObj.Domain,
-		VerifyType: reqObj.VerifyType,
-		Value:      value,
-		ReqID:      "example_req_id", // same as workflow task id
+		Domain:      reqObj.Domain,
+		VerifyType:  string(method),
+		RecordName:  challenge.RecordName,
+		Path:        challenge.Path,
+		Value:       challenge.Value,
+		ReqID:       challenge.ID,
+		MaxAttempts: challenge.MaxAttempts,
+		ExpireAt:    challenge.ExpireAt.Format(time.RFC3339),
 	}
-
-	// db save reqID , domain, verifyType, value, status(pending)
-	//hs.db.Save(&RespObj)
+	rlog.Info().Str("domain", reqObj.Domain).Str("owner", reqObj.Owner).Str("verify_type", reqObj.VerifyType).
+		Str("req_id", challenge.ID).Msg("Ownership challenge issued")
 
 	c.JSON(200, respObj)
 
@@ -58,6 +91,9 @@ func (hs *HubServer) HandleOwnershipVerify(c *gin.Context) {
 	// 提交域名，验证请求ID
 	// 检查对应验证结果 或者当前进度
 
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
 	type ReqObj struct {
 		Domain string `form:"domain" binding:"required"`
 		ReqID  string `form:"req_id" binding:"required"`
@@ -69,54 +105,81 @@ func (hs *HubServer) HandleOwnershipVerify(c *gin.Context) {
 		return
 	}
 
-	verifyType := "dns" // from db reqID
+	domain := model.XLDomain{Name: reqObj.Domain}
+	if err := domain.Normalize(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reqObj.Domain = domain.Name
 
-	finish := false
-	switch verifyType {
-	case "dns":
-		finish = hs.checkDNSRecords(reqObj.Domain)
-	case "file":
-		finish = hs.checkFileUpload(reqObj.Domain)
+	// 验证方式、所有者及 token 均取自保存的挑战
+	challenge, err := hs.ownership.Verify(c.Request.Context(), reqObj.ReqID, domain.BaseName())
+	switch {
+	case errors.Is(err, service.ErrChallengeNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrChallengeDomainMismatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		rlog.Error().Err(err).Str("req_id", reqObj.ReqID).Msg("Failed to verify ownership challenge")
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
 	type RespObj struct {
-		Domain string `json:"domain"`
-		Status string `json:"status"` // pending | verified | failed
-		ReqID  string `json:"req_id"`
+		Domain      string                 `json:"domain"`
+		VerifyType  string                 `json:"verify_type"`
+		Status      string                 `json:"status"` // pending | verified | failed | superseded | revoked
+		ReqID       string                 `json:"req_id"`
+		Message     string                 `json:"message,omitempty"`
+		Attempts    int                    `json:"attempts"`
+		MaxAttempts int                    `json:"max_attempts"`
+		ExpireAt    string                 `json:"expire_at"`
+		LostAt      string                 `json:"lost_at,omitempty"` // 复查未找到证明的时间, 宽限期后停用域名
+		Answers     []model.ResolverAnswer `json:"answers,omitempty"` // dns, cname: 各 DNS 服务器的查询结果
+		Fetches     []model.FileFetch      `json:"fetches,omitempty"` // file: 各次请求的状态码及内容
 	}
-
 	respObj := RespObj{
-		Domain: reqObj.Domain,
-		Status: "pending",
-		ReqID:  reqObj.ReqID,
+		Domain:      reqObj.Domain,
+		VerifyType:  string(challenge.Method),
+		Status:      challenge.Status,
+		ReqID:       challenge.ID,
+		Attempts:    challenge.Attempts,
+		MaxAttempts: challenge.MaxAttempts,
+		ExpireAt:    challenge.ExpireAt.Format(time.RFC3339),
 	}
-	if finish {
-		respObj.Status = "verified"
-		// update db
-
-	} else {
-		respObj.Status = "pending"
+	if challenge.LastCheck != nil {
+		respObj.Answers = challenge.LastCheck.Answers
+		respObj.Fetches = challenge.LastCheck.Fetches
+	}
+	switch challenge.Status {
+	case model.ChallengePending:
+		respObj.Message = challenge.MissingProof()
+		if challenge.LastCheck != nil && challenge.LastCheck.Inconclusive {
+			respObj.Message = "check inconclusive, DNS servers or the site could not be reached"
+		}
+	case model.ChallengeFailed, model.ChallengeRevoked:
+		respObj.Message = challenge.Reason
+	}
+	if !challenge.LostAt.IsZero() {
+		respObj.LostAt = challenge.LostAt.Format(time.RFC3339)
+		respObj.Message = challenge.MissingProof()
 	}
 	c.JSON(200, respObj)
 
 }
 
-func (hs *HubServer) makeTXTStr(domain string) string {
-	// 生成 TXT 记录验证字符串
-	return domain + "example TXT record" // hash(domain, ts, etc...)
-}
-
-func (hs *HubServer) makeFile(domain string) (name, value string) {
-	// 生成 CNAME 记录验证字符串
-	return "example.com", domain + "cname_value"
-}
-
-func (hs *HubServer) checkDNSRecords(domain string) bool {
-	// 检查 DNS 记录
-	return true
-}
-
-func (hs *HubServer) checkFileUpload(domain string) bool {
-	// 检查文件上传
-	return true
+// parseVerifyType 校验验证方式, 不支持时响应 400 并返回 false
+func parseVerifyType(c *gin.Context, verifyType string) (model.VerifyMethod, bool) {
+	method := model.VerifyMethod(verifyType)
+	if method.IsValid() {
+		return method, true
+	}
+	supported := make([]string, len(model.VerifyMethods))
+	for i, m := range model.VerifyMethods {
+		supported[i] = string(m)
+	}
+	c.JSON(400, gin.H{"error": fmt.Sprintf("unsupported verify_type: %q, expected one of %s", verifyType, strings.Join(supported, ", "))})
+	return "", false
 }
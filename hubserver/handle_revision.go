@@ -0,0 +1,121 @@
+package hubserver
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/workflow"
+)
+
+// operatorHeader 操作人, 记录到配置版本的 author
+const operatorHeader = "X-Operator"
+
+// operator 请求的操作人, 未提供时为 fallback
+func operator(c *gin.Context, fallback string) string {
+	if op := c.GetHeader(operatorHeader); op != "" {
+		return op
+	}
+	return fallback
+}
+
+// HandleListRevisions GET /api/v1/domains/:id/revisions 域名的配置版本, 按版本号倒序分页
+func (hs *HubServer) HandleListRevisions(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	page, size, err := parsePage(c)
+	if err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+	domainID := c.Param("id")
+	revs, total, err := hs.workflow.ListRevisions(c.Request.Context(), domainID, page, size)
+	if err != nil {
+		rlog.Error().Err(err).Str("domain_id", domainID).Msg("Failed to list revisions")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	for i := range revs {
+		revs[i].Snapshot = revs[i].Snapshot.Redacted()
+	}
+	resp := model.NewPageResponse(revs, total, page, size)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandleDiffRevisions GET /api/v1/domains/:id/revisions/diff?from=1&to=2 两个版本间的配置差异
+//
+//	to 为空时与当前版本比较
+func (hs *HubServer) HandleDiffRevisions(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	domainID := c.Param("id")
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, "invalid from: "+c.Query("from")))
+		return
+	}
+	var to int
+	if v := c.Query("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to < 1 {
+			c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, "invalid to: "+v))
+			return
+		}
+	} else {
+		domain, err := hs.workflow.GetDomain(c.Request.Context(), domainID)
+		if err != nil {
+			rlog.Error().Err(err).Str("domain_id", domainID).Msg("Failed to get domain")
+			c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+			return
+		}
+		if domain == nil {
+			c.JSON(404, model.NewErrorResponse(model.CodeNotFound, workflow.ErrDomainNotFound.Error()))
+			return
+		}
+		to = domain.Revision
+	}
+
+	changes, err := hs.workflow.DiffRevisions(c.Request.Context(), domainID, from, to)
+	if errors.Is(err, workflow.ErrRevisionNotFound) {
+		c.JSON(404, model.NewErrorResponse(model.CodeNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		rlog.Error().Err(err).Str("domain_id", domainID).Msg("Failed to diff revisions")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+
+	resp := model.NewSuccessResponse(model.RevisionDiffResponse{
+		DomainID: domainID,
+		From:     from,
+		To:       to,
+		Changes:  changes,
+	})
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandleRollback POST /api/v1/domains/:id/rollback 回滚到历史版本
+//
+//	{"revision": 3}, 以该版本的配置提交 update_domain 任务推送到全部 vendor, 返回任务ID
+func (hs *HubServer) HandleRollback(c *gin.Context) {
+	reqid := c.GetString("reqid")
+
+	type ReqObj struct {
+		Revision int `json:"revision" binding:"required,min=1"`
+	}
+	var reqObj ReqObj
+	if err := c.ShouldBindJSON(&reqObj); err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+
+	result, err := hs.workflow.RollbackDomain(c.Request.Context(), c.Param("id"), reqObj.Revision, hs.domainTaskOptions(c, reqid))
+	hs.respondDomainTask(c, reqid, "rollback", result, err)
+}
@@ -0,0 +1,178 @@
+package hubserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+	"centralHub/workflow"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// HandleGetTask GET /tasks/:id 查询任务整体状态及各步骤执行情况
+func (hs *HubServer) HandleGetTask(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	taskID := c.Param("id")
+	task, err := hs.workflow.GetTask(c.Request.Context(), taskID)
+	if err != nil {
+		rlog.Error().Err(err).Str("task_id", taskID).Msg("Failed to get task")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	if task == nil {
+		c.JSON(404, model.NewErrorResponse(model.CodeNotFound, "task not found"))
+		return
+	}
+
+	task.Input = task.Input.Redacted()
+	resp := model.NewSuccessResponse(task)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandleListTasks GET /tasks 按 domain/owner/state/时间范围 分页查询任务
+//
+//	?domain=&owner=&state=&from=&to=&page=1&size=20, from/to 为 RFC3339 时间
+func (hs *HubServer) HandleListTasks(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	type ReqObj struct {
+		Domain string `form:"domain"`
+		Owner  string `form:"owner"`
+		State  string `form:"state"`
+		From   string `form:"from"`
+		To     string `form:"to"`
+	}
+	var reqObj ReqObj
+	if err := c.ShouldBindQuery(&reqObj); err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+
+	filter := store.TaskFilter{
+		Domain: reqObj.Domain,
+		Owner:  reqObj.Owner,
+		State:  model.TaskState(reqObj.State),
+	}
+	if reqObj.State != "" && !filter.State.IsValid() {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, "invalid state: "+reqObj.State))
+		return
+	}
+	var err error
+	if filter.From, err = parseTimeParam(reqObj.From); err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+	if filter.To, err = parseTimeParam(reqObj.To); err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+
+	page, size, err := parsePage(c)
+	if err != nil {
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+
+	tasks, total, err := hs.workflow.ListTasks(c.Request.Context(), filter, page, size)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed to list tasks")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	for i := range tasks {
+		tasks[i].Input = tasks[i].Input.Redacted()
+	}
+	resp := model.NewPageResponse(tasks, total, page, size)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// HandleCancelTask POST /tasks/:id/cancel 取消任务, 已完成的步骤会被补偿
+func (hs *HubServer) HandleCancelTask(c *gin.Context) {
+	hs.handleTaskControl(c, "cancel", hs.workflow.CancelTask)
+}
+
+// HandlePauseTask POST /tasks/:id/pause 暂停任务
+func (hs *HubServer) HandlePauseTask(c *gin.Context) {
+	hs.handleTaskControl(c, "pause", hs.workflow.PauseTask)
+}
+
+// HandleResumeTask POST /tasks/:id/resume 从最后完成的步骤继续执行暂停的任务
+func (hs *HubServer) HandleResumeTask(c *gin.Context) {
+	hs.handleTaskControl(c, "resume", hs.workflow.ResumeTask)
+}
+
+// handleTaskControl 执行控制操作并返回任务最新状态
+func (hs *HubServer) handleTaskControl(c *gin.Context, op string, fn func(ctx context.Context, taskID string) error) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	taskID := c.Param("id")
+	err := fn(c.Request.Context(), taskID)
+	switch {
+	case errors.Is(err, workflow.ErrTaskNotFound):
+		c.JSON(404, model.NewErrorResponse(model.CodeNotFound, err.Error()))
+		return
+	case errors.Is(err, workflow.ErrInvalidTaskState):
+		c.JSON(409, model.NewErrorResponse(model.CodeConflict, err.Error()))
+		return
+	case err != nil:
+		rlog.Error().Err(err).Str("task_id", taskID).Str("op", op).Msg("Task control failed")
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, err.Error()))
+		return
+	}
+	rlog.Info().Str("task_id", taskID).Str("op", op).Msg("Task control accepted")
+
+	task, err := hs.workflow.GetTask(c.Request.Context(), taskID)
+	if err != nil || task == nil {
+		c.JSON(500, model.NewErrorResponse(model.CodeServerError, "reload task failed"))
+		return
+	}
+	task.Input = task.Input.Redacted()
+	resp := model.NewSuccessResponse(task)
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
+
+// parsePage 解析分页参数 page(从1开始)/size
+func parsePage(c *gin.Context) (page, size int, err error) {
+	page, size = 1, defaultPageSize
+	if v := c.Query("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page: %s", v)
+		}
+	}
+	if v := c.Query("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 || size > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid size: %s, must be in [1,%d]", v, maxPageSize)
+		}
+	}
+	return page, size, nil
+}
+
+// parseTimeParam 解析 RFC3339 时间参数, 空值返回零值
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC3339", v)
+	}
+	return t, nil
+}
@@ -0,0 +1,38 @@
+package hubserver
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/workflow"
+)
+
+// HandleVendorDryRun POST /vendors/dry-run 预览域名会落在哪些 vendor 上, 不创建任务
+//
+//	请求体与 /create 相同
+func (hs *HubServer) HandleVendorDryRun(c *gin.Context) {
+	reqid := c.GetString("reqid")
+	rlog := logger.WithReqID(reqid)
+
+	var reqObj model.AddDomainRequest
+	if err := c.ShouldBind(&reqObj); err != nil {
+		rlog.Error().Err(err).Msg("Failed to bind request data")
+		c.JSON(400, model.NewErrorResponse(model.CodeBadRequest, err.Error()))
+		return
+	}
+	if !checkDomainConfig(c, reqid, &reqObj.Domain) {
+		return
+	}
+
+	decisions := hs.workflow.PlanVendors(reqObj.Domain)
+	rlog.Info().Str("domain", reqObj.Domain.Name).Strs("vendors", workflow.SelectedVendors(decisions)).Msg("Vendor dry run")
+
+	resp := model.NewSuccessResponse(model.VendorPlanResponse{
+		Domain:    reqObj.Domain.Name,
+		Vendors:   workflow.SelectedVendors(decisions),
+		Decisions: decisions,
+	})
+	resp.TraceID = reqid
+	c.JSON(200, resp)
+}
@@ -1,22 +1,33 @@
 package hubserver
 
 import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"centralHub/config"
+	"centralHub/service"
 	"centralHub/workflow"
 )
 
 type HubServer struct {
-	workflow *workflow.Workflow
+	workflow  *workflow.Workflow
+	ownership *service.OwnershipService
 }
 
-func NewHubServer() *HubServer {
-	return &HubServer{
-		workflow: workflow.NewWorkflow(),
+func NewHubServer(cfg *config.Config, db *mongo.Database) (*HubServer, error) {
+	wf, err := workflow.NewWorkflow(db, cfg)
+	if err != nil {
+		return nil, err
 	}
+	return &HubServer{
+		workflow:  wf,
+		ownership: wf.Ownership(),
+	}, nil
 }
 
-func (hs *HubServer) getOwnership(domain string) (string, error) {
-
-	// db query
-	// hs.ownershipDB.get(domain)
-	return "", nil
+// Start 启动后台任务执行及所有权复查
+func (hs *HubServer) Start(ctx context.Context) {
+	hs.workflow.Start(ctx)
+	hs.ownership.StartRecheck(ctx, hs.workflow)
 }
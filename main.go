@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
 
@@ -10,13 +12,23 @@ import (
 	"centralHub/hubserver"
 	"centralHub/logger"
 	"centralHub/middleware"
+	"centralHub/store"
 )
 
 func main() {
 	// Load configuration
 	cfg := loadConfig()
 
-	hubServer := hubserver.NewHubServer()
+	db, err := store.NewMongoDatabase(cfg.Database.MongoDB)
+	if err != nil {
+		logger.RunLogger.Fatal().Err(err).Msg("Failed to connect MongoDB")
+	}
+
+	hubServer, err := hubserver.NewHubServer(cfg, db)
+	if err != nil {
+		logger.RunLogger.Fatal().Err(err).Msg("Failed to init hub server")
+	}
+	hubServer.Start(context.Background())
 
 	router := setupRouter(hubServer, cfg)
 
@@ -26,7 +38,7 @@ func main() {
 	}
 }
 
-// loadConfig loads configuration from file or uses defaults
+// loadConfig loads configuration from file, or uses defaults when the file does not exist
 func loadConfig() *config.Config {
 	// Define command line flag for config file path
 	configPath := flag.String("config", "config.yaml", "path to config file")
@@ -34,16 +46,25 @@ func loadConfig() *config.Config {
 
 	// Try to load config file
 	cfg, err := config.Load(*configPath)
-	if err != nil {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
 		// If config file not found, log warning and use defaults
 		logger.RunLogger.Warn().
 			Err(err).
 			Str("config_path", *configPath).
-			Msg("Failed to load config file, using defaults")
-
-		// Return default configuration
-		cfg = getDefaultConfig()
-	} else {
+			Msg("Config file not found, using defaults")
+
+		cfg, err = config.Default()
+		if err != nil {
+			logger.RunLogger.Fatal().Err(err).Msg("Invalid default configuration")
+		}
+	case err != nil:
+		// parse or validation errors must be fixed, never fall back to defaults
+		logger.RunLogger.Fatal().
+			Err(err).
+			Str("config_path", *configPath).
+			Msg("Failed to load config file")
+	default:
 		logger.RunLogger.Info().
 			Str("config_path", *configPath).
 			Msg("Configuration loaded successfully")
@@ -52,39 +73,6 @@ func loadConfig() *config.Config {
 	return cfg
 }
 
-// getDefaultConfig returns a default configuration
-func getDefaultConfig() *config.Config {
-	return &config.Config{
-		Server: config.ServerConfig{
-			Port:    "8080",
-			Mode:    "debug",
-			Timeout: 30,
-		},
-		Database: config.DatabaseConfig{
-			MongoDB: config.MongoDBConfig{
-				URI:      "mongodb://localhost:27017",
-				Database: "centralhub",
-				Timeout:  10,
-			},
-		},
-		Logger: config.LoggerConfig{
-			Level:      "info",
-			Output:     "stdout",
-			FilePath:   "logs/app.log",
-			MaxSize:    100,
-			MaxBackups: 3,
-			MaxAge:     7,
-		},
-		External: config.ExternalConfig{
-			Volcengine: config.VolcengineConfig{
-				AccessKey: "",
-				SecretKey: "",
-				Region:    "cn-beijing",
-			},
-		},
-	}
-}
-
 func setupRouter(hubServer *hubserver.HubServer, cfg *config.Config) *gin.Engine {
 	// Initialize logger based on config
 	isProd := cfg.IsProduction()
@@ -108,7 +96,7 @@ func setupRouter(hubServer *hubserver.HubServer, cfg *config.Config) *gin.Engine
 	// tracing
 	// recovery
 	// Add custom middleware
-	r.Use(middleware.AuditLog())
+	r.Use(middleware.AuditLogWithReqID())
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -122,5 +110,33 @@ func setupRouter(hubServer *hubserver.HubServer, cfg *config.Config) *gin.Engine
 	r.POST("/create", hubServer.HandleCreate)
 	r.GET("/query", hubServer.HandleQuery)
 
+	// Domain ownership
+	r.POST("/ownership/check", hubServer.HandleOwnershipCheck)
+	r.POST("/ownership/verify", hubServer.HandleOwnershipVerify)
+
+	// Workflow tasks
+	r.GET("/tasks", hubServer.HandleListTasks)
+	r.GET("/tasks/:id", hubServer.HandleGetTask)
+	r.POST("/tasks/:id/cancel", hubServer.HandleCancelTask)
+	r.POST("/tasks/:id/pause", hubServer.HandlePauseTask)
+	r.POST("/tasks/:id/resume", hubServer.HandleResumeTask)
+
+	// Domains
+	v1 := r.Group("/api/v1")
+	v1.GET("/domains", hubServer.HandleListDomains)
+	v1.POST("/domains", hubServer.HandleCreate)
+	v1.GET("/domains/:id", hubServer.HandleGetDomain)
+	v1.PATCH("/domains/:id", hubServer.HandlePatchDomain)
+	v1.DELETE("/domains/:id", hubServer.HandleDeleteDomain)
+	v1.POST("/domains/:id/enable", hubServer.HandleEnableDomain)
+	v1.POST("/domains/:id/disable", hubServer.HandleDisableDomain)
+	v1.GET("/domains/:id/revisions", hubServer.HandleListRevisions)
+	v1.GET("/domains/:id/revisions/diff", hubServer.HandleDiffRevisions)
+	v1.POST("/domains/:id/rollback", hubServer.HandleRollback)
+
+	// Vendors
+	r.POST("/vendors/dry-run", hubServer.HandleVendorDryRun)
+	r.POST("/callbacks/:vendor", hubServer.HandleVendorCallback)
+
 	return r
 }
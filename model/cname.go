@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// CNAMEReservation 为域名分配的 cname, 一个域名只占用一个 cname
+type CNAMEReservation struct {
+	CNAME     string    `json:"cname" bson:"_id"`                 // 完整 cname, 如 example-com-1a2b3c4d.xldns.com
+	Domain    string    `json:"domain" bson:"domain"`             // 加速域名
+	Owner     string    `json:"owner" bson:"owner"`               // 租户
+	Zone      string    `json:"zone" bson:"zone"`                 // 托管的 zone, 即 cname 后缀
+	SubDomain string    `json:"sub_domain" bson:"sub_domain"`     // zone 下的主机记录
+	TaskID    string    `json:"task_id,omitempty" bson:"task_id"` // 分配 cname 的任务
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
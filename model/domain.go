@@ -1,42 +1,222 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // reference:
 
 // xunli Domain 配置
 
 type XLDomain struct {
-	ID     string `bson:"_id,omitempty"`
-	Name   string `bson:"name"`
-	Owner  string `bson:"owner"`
-	Status string
+	ID    string `json:"id,omitempty" bson:"_id,omitempty"`
+	Name  string `json:"name" bson:"name"`
+	Owner string `json:"owner" bson:"owner"`
+	// 生命周期状态, 只能通过状态变更修改, 见 DomainStatus
+	Status DomainStatus `json:"status,omitempty" bson:"status,omitempty"`
+	// 最近的状态变更, 最多保留 MaxStatusHistory 条
+	StatusHistory []StatusRecord `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	// 域名类型, 由 Normalize 根据域名设置
+	Type DomainType `json:"type,omitempty" bson:"type,omitempty"`
+	// 配置结构版本, 见 DomainConfigVersion
+	ConfigVersion int `json:"config_version,omitempty" bson:"config_version,omitempty"`
+
+	// cname 记录, 由平台分配, 提交时忽略
+	CNAME string `json:"cname,omitempty" bson:"cname,omitempty"`
+	// 已部署的 vendor, 由创建任务写入
+	ActiveVendors []string `json:"active_vendors,omitempty" bson:"active_vendors,omitempty"`
+	// cname 的 DNS 记录ID, 删除域名时使用
+	DNSRecordID string `json:"dns_record_id,omitempty" bson:"dns_record_id,omitempty"`
+	// 当前配置版本号, 每次配置变更递增, 见 DomainRevision
+	Revision  int       `json:"revision,omitempty" bson:"revision,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	// 指定的 CDN 提供商, 为空时按选择策略
+	Vendors []string `json:"vendors,omitempty" bson:"vendors,omitempty"`
+	// cname 记录的解析线路, 目前只支持 default
+	Lines []string `json:"lines,omitempty" bson:"lines,omitempty"`
+
+	// 回源配置, 由各 vendor 适配器转换为各自的源站配置
+	Origins        []Origin `json:"origins,omitempty" bson:"origins,omitempty"`
+	OriginHost     string   `json:"origin_host,omitempty" bson:"origin_host,omitempty"`         // 回源 Host, 为空时使用加速域名
+	OriginProtocol string   `json:"origin_protocol,omitempty" bson:"origin_protocol,omitempty"` // http, https, followclient
+	ServiceType    string   `json:"service_type,omitempty" bson:"service_type,omitempty"`       // web, download, video
+
+	// vendor 选择依据
+	Region    string   `json:"region,omitempty" bson:"region,omitempty"`         // 服务区域: mainland, overseas, global, 默认 mainland
+	ICPStatus string   `json:"icp_status,omitempty" bson:"icp_status,omitempty"` // approved, none
+	ICPNumber string   `json:"icp_number,omitempty" bson:"icp_number,omitempty"` // 备案号, 暂不支持
+	Features  []string `json:"features,omitempty" bson:"features,omitempty"`     // 需要的功能: https, http2, quic, ipv6
+
+	Cache    *CacheConfig    `json:"cache,omitempty" bson:"cache,omitempty"`
+	Access   *AccessConfig   `json:"access,omitempty" bson:"access,omitempty"` // 黑白名单, 防盗链, 鉴权
+	HTTPS    *HTTPSConfig    `json:"https,omitempty" bson:"https,omitempty"`   // https, http2, 证书
+	Redirect *RedirectConfig `json:"redirect,omitempty" bson:"redirect,omitempty"`
 }
 
-type XLPlatformInfo struct {
-	ReverseName string
-	UserID      string
+// 服务区域
+const (
+	RegionMainland = "mainland" // 中国大陆, 需要备案
+	RegionOverseas = "overseas"
+	RegionGlobal   = "global"
+)
+
+// 备案状态
+const (
+	ICPApproved = "approved"
+	ICPNone     = "none"
+)
+
+// IsValidRegion 是否为已定义的服务区域
+func IsValidRegion(region string) bool {
+	switch region {
+	case RegionMainland, RegionOverseas, RegionGlobal:
+		return true
+	}
+	return false
 }
 
-type StatusRecord struct {
-	CreateAt int64
-	UpdateAt int64
+// ServiceRegion 服务区域, 未指定时为 mainland
+func (d XLDomain) ServiceRegion() string {
+	if d.Region == "" {
+		return RegionMainland
+	}
+	return d.Region
 }
 
-// 属性： 类型，泛域名
+// ConfigSnapshot 域名配置快照, 去掉由平台维护的运行时字段
+func (d XLDomain) ConfigSnapshot() XLDomain {
+	d.ID = ""
+	d.Status = ""
+	d.StatusHistory = nil
+	d.CNAME = ""
+	d.ActiveVendors = nil
+	d.DNSRecordID = ""
+	d.Revision = 0
+	d.CreatedAt = time.Time{}
+	d.UpdatedAt = time.Time{}
+	return d
+}
 
-// cname 记录
+// WithConfig 用 cfg 的配置替换当前配置, 保留域名、所有者及运行时字段
+func (d XLDomain) WithConfig(cfg XLDomain) XLDomain {
+	out := cfg.ConfigSnapshot()
+	out.ID = d.ID
+	out.Name = d.Name
+	out.Owner = d.Owner
+	out.Type = d.Type
+	out.Status = d.Status
+	out.StatusHistory = d.StatusHistory
+	out.CNAME = d.CNAME
+	out.ActiveVendors = d.ActiveVendors
+	out.DNSRecordID = d.DNSRecordID
+	out.Revision = d.Revision
+	out.CreatedAt = d.CreatedAt
+	out.UpdatedAt = d.UpdatedAt
+	return out
+}
 
-// cdn 提供商
+// DomainType 域名类型
+type DomainType string
 
-// 线路 -->
+const (
+	DomainNormal   DomainType = "normal"
+	DomainWildcard DomainType = "wildcard" // 泛域名, 匹配一层子域名
+)
 
-// 备案
+// IsWildcard 是否泛域名, 形如 *.example.com 或 .example.com
+func (d XLDomain) IsWildcard() bool {
+	return d.Type == DomainWildcard || strings.HasPrefix(d.Name, "*.") || strings.HasPrefix(d.Name, ".")
+}
 
-// cache 缓存
+// BaseName 泛域名去掉 "*." 后的域名, 普通域名返回自身
+// 所有权验证、备案查询以它为准
+func (d XLDomain) BaseName() string {
+	return strings.TrimPrefix(strings.TrimPrefix(d.Name, "*"), ".")
+}
 
-// source 源
+// Normalize 规范化域名: 转小写, 去掉末尾的 ".", 泛域名统一为 *.example.com, 并设置类型及配置版本
+func (d *XLDomain) Normalize() error {
+	name, err := NormalizeDomainName(d.Name)
+	if err != nil {
+		return err
+	}
+	d.Name = name
+	if d.ConfigVersion == 0 {
+		d.ConfigVersion = DomainConfigVersion
+	}
+	d.Type = DomainNormal
+	if strings.HasPrefix(name, "*.") {
+		d.Type = DomainWildcard
+	}
+	return nil
+}
+
+// NormalizeDomainName 规范化域名, ".example.com" 转为 "*.example.com"
+func NormalizeDomainName(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if strings.HasPrefix(name, ".") {
+		name = "*" + name
+	}
+	base := strings.TrimPrefix(name, "*.")
+	if base == "" || len(name) > 253 {
+		return "", fmt.Errorf("invalid domain name: %q", name)
+	}
+	labels := strings.Split(base, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("invalid domain name: %q", name)
+	}
+	for _, label := range labels {
+		if !isValidLabel(label) {
+			return "", fmt.Errorf("invalid domain name: %q", name)
+		}
+	}
+	return name, nil
+}
+
+// isValidLabel 域名的一层: 1-63 位字母、数字、"-", 不以 "-" 开头或结尾
+func isValidLabel(label string) bool {
+	if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// WildcardParent 普通域名所属的泛域名, 如 a.example.com -> *.example.com
+// 只有一层的域名(如 example.com)返回空串
+func WildcardParent(name string) string {
+	i := strings.Index(name, ".")
+	if i < 0 || !strings.Contains(name[i+1:], ".") {
+		return ""
+	}
+	return "*" + name[i:]
+}
 
-// 访问：黑白名单 防盗链 鉴权
+// OriginType 源站类型
+type OriginType string
 
-// https http2 证书
+const (
+	OriginPrimary OriginType = "primary"
+	OriginBackup  OriginType = "backup"
+)
 
-// 302 配置
+// Origin 源站, 地址为 IP 或域名
+type Origin struct {
+	Address   string     `json:"address" bson:"address"`
+	Type      OriginType `json:"type,omitempty" bson:"type,omitempty"` // 默认 primary
+	HTTPPort  int        `json:"http_port,omitempty" bson:"http_port,omitempty"`
+	HTTPSPort int        `json:"https_port,omitempty" bson:"https_port,omitempty"`
+	Weight    int        `json:"weight,omitempty" bson:"weight,omitempty"`
+}
+
+type XLPlatformInfo struct {
+	ReverseName string
+	UserID      string
+}
@@ -0,0 +1,417 @@
+package model
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DomainConfigVersion 当前域名配置结构的版本
+// 结构有不兼容变更时递增, 读取旧版本配置时按 config_version 转换
+const DomainConfigVersion = 1
+
+// DNS 解析线路
+const (
+	LineDefault  = "default"
+	LineTelecom  = "telecom"
+	LineUnicom   = "unicom"
+	LineMobile   = "mobile"
+	LineEdu      = "edu"
+	LineOverseas = "overseas"
+)
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	Rules       []CacheRule `json:"rules,omitempty" bson:"rules,omitempty"` // 按顺序匹配, 先匹配的生效
+	IgnoreQuery bool        `json:"ignore_query,omitempty" bson:"ignore_query,omitempty"`
+}
+
+// CacheRuleType 缓存规则的匹配方式
+type CacheRuleType string
+
+const (
+	CacheRuleAll       CacheRuleType = "all"       // 全部文件
+	CacheRuleSuffix    CacheRuleType = "suffix"    // 文件后缀, value 如 "jpg,png"
+	CacheRuleDirectory CacheRuleType = "directory" // 目录, value 如 "/static/"
+	CacheRulePath      CacheRuleType = "path"      // 完整路径, value 如 "/index.html"
+)
+
+// CacheRule 缓存规则, TTL 为 0 表示不缓存
+type CacheRule struct {
+	Type  CacheRuleType `json:"type" bson:"type"`
+	Value string        `json:"value,omitempty" bson:"value,omitempty"`
+	TTL   int64         `json:"ttl" bson:"ttl"` // 秒
+}
+
+// 缓存时间上限
+const maxCacheTTL = 365 * 24 * 3600
+
+// AccessConfig 访问控制: IP 黑白名单, 防盗链, URL 鉴权
+type AccessConfig struct {
+	IPBlacklist []string       `json:"ip_blacklist,omitempty" bson:"ip_blacklist,omitempty"` // IP 或 CIDR
+	IPWhitelist []string       `json:"ip_whitelist,omitempty" bson:"ip_whitelist,omitempty"` // 与黑名单二选一
+	Referer     *RefererConfig `json:"referer,omitempty" bson:"referer,omitempty"`
+	Auth        *URLAuthConfig `json:"auth,omitempty" bson:"auth,omitempty"`
+}
+
+// ListType 名单类型
+type ListType string
+
+const (
+	ListBlack ListType = "blacklist"
+	ListWhite ListType = "whitelist"
+)
+
+// RefererConfig 防盗链, 按 Referer 头过滤
+type RefererConfig struct {
+	Type       ListType `json:"type" bson:"type"`
+	Values     []string `json:"values" bson:"values"` // 域名, 支持 *.example.com
+	AllowEmpty bool     `json:"allow_empty,omitempty" bson:"allow_empty,omitempty"`
+}
+
+// URLAuthType URL 鉴权方式, 对应各厂商通用的 A/B/C/D 四种签名方式
+type URLAuthType string
+
+const (
+	URLAuthTypeA URLAuthType = "type_a"
+	URLAuthTypeB URLAuthType = "type_b"
+	URLAuthTypeC URLAuthType = "type_c"
+	URLAuthTypeD URLAuthType = "type_d"
+)
+
+// URLAuthConfig URL 鉴权
+type URLAuthConfig struct {
+	Type       URLAuthType `json:"type" bson:"type"`
+	PrimaryKey string      `json:"primary_key" bson:"primary_key"`
+	BackupKey  string      `json:"backup_key,omitempty" bson:"backup_key,omitempty"`
+	TTL        int64       `json:"ttl" bson:"ttl"`                                   // 签名有效期, 秒
+	ParamName  string      `json:"param_name,omitempty" bson:"param_name,omitempty"` // type_a/type_d 的签名参数名
+}
+
+// HTTPSConfig HTTPS 及证书配置
+// 证书二选一: 已托管的证书 cert_id, 或直接提供 certificate + private_key(PEM)
+type HTTPSConfig struct {
+	Enabled       bool     `json:"enabled" bson:"enabled"`
+	CertID        string   `json:"cert_id,omitempty" bson:"cert_id,omitempty"`
+	Certificate   string   `json:"certificate,omitempty" bson:"certificate,omitempty"`
+	PrivateKey    string   `json:"private_key,omitempty" bson:"private_key,omitempty"`
+	ForceRedirect bool     `json:"force_redirect,omitempty" bson:"force_redirect,omitempty"` // http 跳转 https
+	HTTP2         bool     `json:"http2,omitempty" bson:"http2,omitempty"`
+	TLSVersions   []string `json:"tls_versions,omitempty" bson:"tls_versions,omitempty"` // tlsv1.0 ~ tlsv1.3
+}
+
+// RedirectConfig 302 配置: 回源时跟随源站返回的 301/302
+type RedirectConfig struct {
+	FollowOrigin bool `json:"follow_origin" bson:"follow_origin"`
+	MaxFollow    int  `json:"max_follow,omitempty" bson:"max_follow,omitempty"` // 最多跟随次数, 暂不支持, 由 vendor 决定
+}
+
+// FieldError 字段校验错误, Field 为 json 路径, 如 origins[0].address
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 域名配置校验错误, 包含全部不合法的字段
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "invalid domain config: " + strings.Join(msgs, "; ")
+}
+
+// fieldErrors 收集校验错误
+type fieldErrors []FieldError
+
+func (fe *fieldErrors) add(field, format string, args ...interface{}) {
+	*fe = append(*fe, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+/*
+Validate 校验域名配置, 有不合法字段时返回 *ValidationError
+只做与 vendor 无关的检查, vendor 是否支持由选择策略判断
+*/
+func (d XLDomain) Validate() error {
+	var errs fieldErrors
+
+	if _, err := NormalizeDomainName(d.Name); err != nil {
+		errs.add("name", "invalid domain name")
+	}
+	if d.ConfigVersion < 0 || d.ConfigVersion > DomainConfigVersion {
+		errs.add("config_version", "unsupported version %d, current is %d", d.ConfigVersion, DomainConfigVersion)
+	}
+	d.validateOrigin(&errs)
+	d.validateSelection(&errs)
+	if d.Cache != nil {
+		d.Cache.validate(&errs, "cache")
+	}
+	if d.Access != nil {
+		d.Access.validate(&errs, "access")
+	}
+	if d.HTTPS != nil {
+		d.HTTPS.validate(&errs, "https")
+	}
+	// vendor 均不支持设置跟随次数
+	if d.Redirect != nil && d.Redirect.MaxFollow != 0 {
+		errs.add("redirect.max_follow", "not supported yet")
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (d XLDomain) validateOrigin(errs *fieldErrors) {
+	primary := 0
+	for i, o := range d.Origins {
+		field := fmt.Sprintf("origins[%d]", i)
+		if o.Address == "" {
+			errs.add(field+".address", "required")
+		} else if net.ParseIP(o.Address) == nil {
+			if _, err := NormalizeDomainName(o.Address); err != nil || strings.HasPrefix(o.Address, "*") {
+				errs.add(field+".address", "must be an IP or domain name")
+			}
+		}
+		switch o.Type {
+		case "", OriginPrimary:
+			primary++
+		case OriginBackup:
+		default:
+			errs.add(field+".type", "must be primary or backup")
+		}
+		if o.HTTPPort < 0 || o.HTTPPort > 65535 {
+			errs.add(field+".http_port", "must be 1-65535")
+		}
+		if o.HTTPSPort < 0 || o.HTTPSPort > 65535 {
+			errs.add(field+".https_port", "must be 1-65535")
+		}
+		if o.Weight < 0 {
+			errs.add(field+".weight", "must not be negative")
+		}
+	}
+	if len(d.Origins) > 0 && primary == 0 {
+		errs.add("origins", "at least one primary origin is required")
+	}
+	switch d.OriginProtocol {
+	case "", "http", "https", "followclient":
+	default:
+		errs.add("origin_protocol", "must be http, https or followclient")
+	}
+	switch d.ServiceType {
+	case "", "web", "download", "video":
+	default:
+		errs.add("service_type", "must be web, download or video")
+	}
+}
+
+// validateSelection vendor 选择及 DNS 相关字段
+func (d XLDomain) validateSelection(errs *fieldErrors) {
+	if d.Region != "" && !IsValidRegion(d.Region) {
+		errs.add("region", "must be mainland, overseas or global")
+	}
+	switch d.ICPStatus {
+	case "", ICPApproved:
+	case ICPNone:
+	default:
+		errs.add("icp_status", "must be approved or none")
+	}
+	// 备案号查询未对接, 只按 icp_status 选择 vendor
+	if d.ICPNumber != "" {
+		errs.add("icp_number", "not supported yet")
+	}
+	for i, f := range d.Features {
+		// 泛域名由域名本身决定, 不作为功能指定
+		if c := VendorCapability(f); !c.IsValid() || c == CapabilityWildcard {
+			errs.add(fmt.Sprintf("features[%d]", i), "unknown feature %s", f)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, v := range d.Vendors {
+		if v == "" || seen[v] {
+			errs.add(fmt.Sprintf("vendors[%d]", i), "empty or duplicate vendor")
+		}
+		seen[v] = true
+	}
+	// DNS 客户端只添加默认线路的记录, 分线路解析未对接
+	for i, l := range d.Lines {
+		switch l {
+		case LineDefault:
+		case LineTelecom, LineUnicom, LineMobile, LineEdu, LineOverseas:
+			errs.add(fmt.Sprintf("lines[%d]", i), "line %s not supported yet", l)
+		default:
+			errs.add(fmt.Sprintf("lines[%d]", i), "unknown line %s", l)
+		}
+	}
+}
+
+func (c *CacheConfig) validate(errs *fieldErrors, prefix string) {
+	for i, r := range c.Rules {
+		field := fmt.Sprintf("%s.rules[%d]", prefix, i)
+		switch r.Type {
+		case CacheRuleAll:
+		case CacheRuleSuffix:
+			if r.Value == "" {
+				errs.add(field+".value", "required")
+			}
+		case CacheRuleDirectory, CacheRulePath:
+			if !strings.HasPrefix(r.Value, "/") {
+				errs.add(field+".value", "must start with /")
+			}
+		default:
+			errs.add(field+".type", "must be all, suffix, directory or path")
+		}
+		if r.TTL < 0 || r.TTL > maxCacheTTL {
+			errs.add(field+".ttl", "must be 0-%d", maxCacheTTL)
+		}
+	}
+}
+
+func (a *AccessConfig) validate(errs *fieldErrors, prefix string) {
+	if len(a.IPBlacklist) > 0 && len(a.IPWhitelist) > 0 {
+		errs.add(prefix+".ip_whitelist", "conflicts with ip_blacklist")
+	}
+	for i, ip := range a.IPBlacklist {
+		if !isIPOrCIDR(ip) {
+			errs.add(fmt.Sprintf("%s.ip_blacklist[%d]", prefix, i), "invalid IP or CIDR %s", ip)
+		}
+	}
+	for i, ip := range a.IPWhitelist {
+		if !isIPOrCIDR(ip) {
+			errs.add(fmt.Sprintf("%s.ip_whitelist[%d]", prefix, i), "invalid IP or CIDR %s", ip)
+		}
+	}
+	if r := a.Referer; r != nil {
+		if r.Type != ListBlack && r.Type != ListWhite {
+			errs.add(prefix+".referer.type", "must be blacklist or whitelist")
+		}
+		if len(r.Values) == 0 {
+			errs.add(prefix+".referer.values", "required")
+		}
+		for i, v := range r.Values {
+			if _, err := NormalizeDomainName(v); err != nil {
+				errs.add(fmt.Sprintf("%s.referer.values[%d]", prefix, i), "invalid domain %s", v)
+			}
+		}
+	}
+	if au := a.Auth; au != nil {
+		switch au.Type {
+		case URLAuthTypeA, URLAuthTypeB, URLAuthTypeC, URLAuthTypeD:
+		default:
+			errs.add(prefix+".auth.type", "must be type_a, type_b, type_c or type_d")
+		}
+		if !isValidAuthKey(au.PrimaryKey) {
+			errs.add(prefix+".auth.primary_key", "must be 6-32 letters or digits")
+		}
+		if au.BackupKey != "" && !isValidAuthKey(au.BackupKey) {
+			errs.add(prefix+".auth.backup_key", "must be 6-32 letters or digits")
+		}
+		if au.TTL <= 0 {
+			errs.add(prefix+".auth.ttl", "must be positive")
+		}
+		if au.ParamName != "" && au.Type != URLAuthTypeA && au.Type != URLAuthTypeD {
+			errs.add(prefix+".auth.param_name", "only for type_a or type_d")
+		}
+	}
+}
+
+func (h *HTTPSConfig) validate(errs *fieldErrors, prefix string) {
+	if !h.Enabled {
+		if h.ForceRedirect || h.HTTP2 {
+			errs.add(prefix+".enabled", "force_redirect and http2 require https")
+		}
+		return
+	}
+	switch {
+	case h.CertID != "" && (h.Certificate != "" || h.PrivateKey != ""):
+		errs.add(prefix+".cert_id", "conflicts with certificate")
+	case h.CertID == "" && (h.Certificate == "" || h.PrivateKey == ""):
+		errs.add(prefix+".certificate", "cert_id or certificate and private_key are required")
+	case h.CertID == "":
+		if b, _ := pem.Decode([]byte(h.Certificate)); b == nil || b.Type != "CERTIFICATE" {
+			errs.add(prefix+".certificate", "must be a PEM encoded certificate")
+		}
+		if b, _ := pem.Decode([]byte(h.PrivateKey)); b == nil || !strings.HasSuffix(b.Type, "PRIVATE KEY") {
+			errs.add(prefix+".private_key", "must be a PEM encoded private key")
+		}
+	}
+	for i, v := range h.TLSVersions {
+		switch v {
+		case "tlsv1.0", "tlsv1.1", "tlsv1.2", "tlsv1.3":
+		default:
+			errs.add(fmt.Sprintf("%s.tls_versions[%d]", prefix, i), "unknown version %s", v)
+		}
+	}
+}
+
+// RequiredCapabilities 域名配置需要 vendor 支持的功能
+func (d XLDomain) RequiredCapabilities() []VendorCapability {
+	var caps []VendorCapability
+	seen := make(map[VendorCapability]bool)
+	add := func(c VendorCapability) {
+		if !seen[c] {
+			seen[c] = true
+			caps = append(caps, c)
+		}
+	}
+	if d.IsWildcard() {
+		add(CapabilityWildcard)
+	}
+	for _, f := range d.Features {
+		add(VendorCapability(f))
+	}
+	if d.HTTPS != nil && d.HTTPS.Enabled {
+		add(CapabilityHTTPS)
+		if d.HTTPS.HTTP2 {
+			add(CapabilityHTTP2)
+		}
+	}
+	return caps
+}
+
+// Redacted 去掉证书私钥和鉴权密钥, 用于接口返回
+func (d XLDomain) Redacted() XLDomain {
+	const mask = secretMask
+	if d.HTTPS != nil && d.HTTPS.PrivateKey != "" {
+		https := *d.HTTPS
+		https.PrivateKey = mask
+		d.HTTPS = &https
+	}
+	if d.Access != nil && d.Access.Auth != nil {
+		access := *d.Access
+		auth := *access.Auth
+		auth.PrimaryKey = mask
+		if auth.BackupKey != "" {
+			auth.BackupKey = mask
+		}
+		access.Auth = &auth
+		d.Access = &access
+	}
+	return d
+}
+
+func isIPOrCIDR(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+func isValidAuthKey(key string) bool {
+	if len(key) < 6 || len(key) > 32 {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// 由平台维护, 不能通过 PATCH 修改的字段
+var immutableDomainFields = []string{
+	"id", "name", "owner", "type", "status", "status_history", "cname", "active_vendors",
+	"dns_record_id", "revision", "created_at", "updated_at",
+}
+
+/*
+ApplyPatch 按 JSON Merge Patch(RFC 7396) 修改域名配置, 返回修改后的域名
+
+	对象按字段合并, 值为 null 表示删除该字段, 数组整体替换
+	修改平台维护的字段或未知字段时返回 *ValidationError
+*/
+func (d XLDomain) ApplyPatch(patch []byte) (XLDomain, error) {
+	var p map[string]interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return d, fmt.Errorf("invalid patch: %w", err)
+	}
+	var errs fieldErrors
+	for _, f := range immutableDomainFields {
+		if _, ok := p[f]; ok {
+			errs.add(f, "can not be modified")
+		}
+	}
+	if len(errs) > 0 {
+		return d, &ValidationError{Errors: errs}
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return d, err
+	}
+	var cur map[string]interface{}
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return d, err
+	}
+	merged, err := json.Marshal(mergePatch(cur, p))
+	if err != nil {
+		return d, err
+	}
+
+	var out XLDomain
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return d, &ValidationError{Errors: []FieldError{{Field: "patch", Message: err.Error()}}}
+	}
+	return out, nil
+}
+
+// mergePatch RFC 7396 合并
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
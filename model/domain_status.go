@@ -0,0 +1,95 @@
+package model
+
+import "time"
+
+// DomainStatus 域名生命周期状态, 只能按 domainTransitions 中的方向变更
+type DomainStatus string
+
+const (
+	DomainConfiguring  DomainStatus = "configuring" // 分配 cname, 创建或更新 vendor 配置
+	DomainDeploying    DomainStatus = "deploying"   // 等待 vendor 上线, 创建 DNS 记录
+	DomainDeployFailed DomainStatus = "deploy_failed"
+	DomainOnline       DomainStatus = "online"
+	DomainDisabled     DomainStatus = "disabled"
+	DomainSuspended    DomainStatus = "suspended" // 所有权验证失效, 已在 vendor 上停用, 重新验证后可启用
+	DomainDeleting     DomainStatus = "deleting"
+	DomainDeleteFailed DomainStatus = "delete_failed"
+	DomainDeleted      DomainStatus = "deleted" // 保留记录及配置版本, 可重新创建
+)
+
+// domainTransitions 允许的状态变更, 空状态表示域名记录尚不存在
+var domainTransitions = map[DomainStatus][]DomainStatus{
+	"": {DomainConfiguring},
+	// 更新配置结束后回到更新前的 online 或 disabled
+	DomainConfiguring:  {DomainDeploying, DomainDeployFailed, DomainOnline, DomainDisabled},
+	DomainDeploying:    {DomainOnline, DomainDeployFailed},
+	DomainDeployFailed: {DomainConfiguring, DomainDeleting},
+	DomainOnline:       {DomainConfiguring, DomainDisabled, DomainSuspended, DomainDeleting},
+	DomainDisabled:     {DomainConfiguring, DomainOnline, DomainSuspended, DomainDeleting},
+	DomainSuspended:    {DomainOnline, DomainDeleting},
+	DomainDeleting:     {DomainDeleted, DomainDeleteFailed},
+	DomainDeleteFailed: {DomainDeleting},
+	DomainDeleted:      {DomainConfiguring},
+}
+
+// IsValid 是否为已定义的状态
+func (s DomainStatus) IsValid() bool {
+	_, ok := domainTransitions[s]
+	return ok && s != ""
+}
+
+// CanTransition 是否允许从 s 变更为 to
+func (s DomainStatus) CanTransition(to DomainStatus) bool {
+	for _, next := range domainTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainOp 对域名的操作
+type DomainOp string
+
+const (
+	DomainOpCreate  DomainOp = "create"
+	DomainOpUpdate  DomainOp = "update" // 修改配置或回滚
+	DomainOpDelete  DomainOp = "delete"
+	DomainOpEnable  DomainOp = "enable"
+	DomainOpDisable DomainOp = "disable"
+	DomainOpSuspend DomainOp = "suspend" // 所有权验证失效后由系统停用
+)
+
+// domainOps 各状态下允许的操作, 进行中的状态(configuring, deploying, deleting)不接受新操作
+var domainOps = map[DomainStatus][]DomainOp{
+	"":                 {DomainOpCreate},
+	DomainDeployFailed: {DomainOpCreate, DomainOpDelete},
+	DomainOnline:       {DomainOpUpdate, DomainOpDelete, DomainOpDisable, DomainOpSuspend},
+	DomainDisabled:     {DomainOpUpdate, DomainOpDelete, DomainOpEnable, DomainOpSuspend},
+	DomainSuspended:    {DomainOpDelete, DomainOpEnable},
+	DomainDeleteFailed: {DomainOpDelete},
+	DomainDeleted:      {DomainOpCreate},
+}
+
+// Allows 当前状态是否允许该操作
+func (s DomainStatus) Allows(op DomainOp) bool {
+	for _, allowed := range domainOps[s] {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusRecord 一次状态变更
+type StatusRecord struct {
+	From   DomainStatus `json:"from" bson:"from"`
+	To     DomainStatus `json:"to" bson:"to"`
+	Actor  string       `json:"actor,omitempty" bson:"actor,omitempty"`
+	Reason string       `json:"reason,omitempty" bson:"reason,omitempty"`
+	TaskID string       `json:"task_id,omitempty" bson:"task_id,omitempty"`
+	At     time.Time    `json:"at" bson:"at"`
+}
+
+// 域名记录中保留的最近状态变更数
+const MaxStatusHistory = 50
@@ -0,0 +1,121 @@
+package model
+
+import "time"
+
+// VerifyMethod 域名所有权验证方式
+type VerifyMethod string
+
+const (
+	VerifyDNS   VerifyMethod = "dns"   // TXT 记录
+	VerifyFile  VerifyMethod = "file"  // 文件
+	VerifyCNAME VerifyMethod = "cname" // 生成的记录名 cname 到平台的目标域名
+)
+
+// VerifyMethods 支持的验证方式
+var VerifyMethods = []VerifyMethod{VerifyDNS, VerifyFile, VerifyCNAME}
+
+// IsValid 是否为支持的验证方式
+func (m VerifyMethod) IsValid() bool {
+	for _, v := range VerifyMethods {
+		if m == v {
+			return true
+		}
+	}
+	return false
+}
+
+// 验证状态
+const (
+	ChallengePending  = "pending"
+	ChallengeVerified = "verified"
+	ChallengeFailed   = "failed"
+	// 同一域名及所有者之后又验证通过, 不再复查
+	ChallengeSuperseded = "superseded"
+	// 复查发现证明消失且超过宽限期, 域名已停用, 需重新验证
+	ChallengeRevoked = "revoked"
+)
+
+// OwnershipChallenge 所有权验证挑战, 用户按 RecordName/Value 发布后以 ID(req_id) 提交验证
+type OwnershipChallenge struct {
+	ID         string       `json:"req_id" bson:"_id"`
+	Domain     string       `json:"domain" bson:"domain"` // 泛域名为去掉 "*." 的域名
+	Owner      string       `json:"owner" bson:"owner"`
+	Method     VerifyMethod `json:"verify_type" bson:"verify_type"`
+	Token      string       `json:"token" bson:"token"`
+	RecordName string       `json:"record_name,omitempty" bson:"record_name,omitempty"` // dns: TXT 记录名, 如 _centralhub-challenge.example.com; cname: 记录名
+	Path       string       `json:"path,omitempty" bson:"path,omitempty"`               // file: 文件的 URL 路径, 如 /.well-known/centralhub/<token>.txt
+	Value      string       `json:"value" bson:"value"`                                 // TXT 记录值, 文件内容或 cname 目标
+
+	Status      string          `json:"status" bson:"status"` // pending | verified | failed | superseded | revoked
+	Reason      string          `json:"reason,omitempty" bson:"reason,omitempty"`
+	Attempts    int             `json:"attempts" bson:"attempts"`         // 已检查次数
+	MaxAttempts int             `json:"max_attempts" bson:"max_attempts"` // 检查次数上限, 用完仍未通过为 failed
+	LastCheck   *OwnershipCheck `json:"last_check,omitempty" bson:"last_check,omitempty"`
+
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+	ExpireAt   time.Time `json:"expire_at" bson:"expire_at"` // 之后仍未通过为 failed
+	VerifiedAt time.Time `json:"verified_at,omitempty" bson:"verified_at,omitempty"`
+	// 通过后定期复查, LostAt 为复查首次未找到证明的时间, 再次找到时清除
+	RecheckedAt time.Time `json:"rechecked_at,omitempty" bson:"rechecked_at,omitempty"`
+	LostAt      time.Time `json:"lost_at,omitempty" bson:"lost_at,omitempty"`
+	// 未通过的挑战到期后由 TTL 索引删除, 通过的挑战不设置
+	PurgeAt time.Time `json:"-" bson:"purge_at,omitempty"`
+}
+
+// MissingProof 未找到证明时的提示
+func (c *OwnershipChallenge) MissingProof() string {
+	switch c.Method {
+	case VerifyFile:
+		return "verification file " + c.Path + " not found"
+	case VerifyCNAME:
+		return "CNAME record " + c.RecordName + " -> " + c.Value + " not found"
+	}
+	return "TXT record " + c.RecordName + " not found"
+}
+
+// ResolverAnswer 单个 DNS 服务器的查询结果, TXT 记录或 cname 目标
+type ResolverAnswer struct {
+	Resolver string   `json:"resolver" bson:"resolver"`
+	Records  []string `json:"records,omitempty" bson:"records,omitempty"`
+	Error    string   `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// FileFetch 一次验证文件的请求结果, 失败时记录观察到的状态码及内容
+type FileFetch struct {
+	URL        string   `json:"url" bson:"url"`
+	Redirects  []string `json:"redirects,omitempty" bson:"redirects,omitempty"` // 跟随的重定向
+	StatusCode int      `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Body       string   `json:"body,omitempty" bson:"body,omitempty"` // 截断
+	Error      string   `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// OwnershipCheck 一次验证的结果
+type OwnershipCheck struct {
+	Verified bool `json:"verified" bson:"verified"`
+	// 未通过且没有确定的结果(超时、SERVFAIL、连接失败、5xx), 不能据此认为证明已消失
+	Inconclusive bool             `json:"inconclusive,omitempty" bson:"inconclusive,omitempty"`
+	Answers      []ResolverAnswer `json:"answers,omitempty" bson:"answers,omitempty"` // dns, cname
+	Fetches      []FileFetch      `json:"fetches,omitempty" bson:"fetches,omitempty"` // file, http 及 https
+	CheckedAt    time.Time        `json:"checked_at" bson:"checked_at"`
+}
+
+// 所有权复查通知的事件
+const (
+	OwnershipLost     = "ownership_lost"     // 未找到证明, 宽限期后停用
+	OwnershipRestored = "ownership_restored" // 宽限期内证明恢复
+	OwnershipRevoked  = "ownership_revoked"  // 超过宽限期, 域名已停用
+)
+
+// OwnershipEvent 复查结果变化时通知所有者
+type OwnershipEvent struct {
+	Event      string       `json:"event"`
+	Domain     string       `json:"domain"`
+	Owner      string       `json:"owner"`
+	ReqID      string       `json:"req_id"`
+	VerifyType VerifyMethod `json:"verify_type"`
+	LostAt     time.Time    `json:"lost_at,omitempty"`
+	SuspendAt  time.Time    `json:"suspend_at,omitempty"` // ownership_lost: 宽限期结束时间
+	TaskIDs    []string     `json:"task_ids,omitempty"`   // ownership_revoked: 停用域名的任务
+	Message    string       `json:"message,omitempty"`
+}
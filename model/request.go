@@ -2,6 +2,26 @@ package model
 
 type AddDomainRequest struct {
 	Domain XLDomain `json:"domain"`
+	// 同一域名已有进行中或已完成的任务时的处理策略, 为空时使用配置的默认策略
+	DuplicatePolicy DuplicatePolicy `json:"duplicate_policy"`
+}
+
+// DuplicatePolicy 重复提交的处理策略
+type DuplicatePolicy string
+
+const (
+	DuplicateReturnExisting DuplicatePolicy = "return_existing" // 返回已有任务
+	DuplicateQueue          DuplicatePolicy = "queue"           // 排在已有任务之后执行
+	DuplicateSupersede      DuplicatePolicy = "supersede"       // 取消已有任务, 由新任务替代
+)
+
+// IsValid 是否为已定义的策略
+func (p DuplicatePolicy) IsValid() bool {
+	switch p {
+	case DuplicateReturnExisting, DuplicateQueue, DuplicateSupersede:
+		return true
+	}
+	return false
 }
 
 type AddDomainResponse struct {
@@ -18,16 +18,41 @@ type PageResponse struct {
 	Total   int64       `json:"total"` // 总数
 	Page    int         `json:"page"`  // 当前页
 	Size    int         `json:"size"`  // 每页大小
+	TraceID string      `json:"trace_id,omitempty"`
 }
 
 // DomainResponse 域名响应结构体
 type DomainResponse struct {
-	ID         string    `json:"id"`
-	DomainName string    `json:"domain_name"`
-	Status     string    `json:"status"`
-	Owner      string    `json:"owner"`
-	CreatedAt  time.Time `json:"created_at,omitempty"`
-	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+	ID         string       `json:"id"`
+	DomainName string       `json:"domain_name"`
+	Type       DomainType   `json:"type,omitempty"`
+	Status     DomainStatus `json:"status"`
+	Owner      string       `json:"owner"`
+	CNAME      string       `json:"cname,omitempty"`
+	Vendors    []string     `json:"vendors,omitempty"` // 已部署的 vendor
+	Revision   int          `json:"revision"`
+	Config     XLDomain     `json:"config"` // 当前配置, 不含密钥
+	// 最近的状态变更, 只在查询单个域名时返回
+	StatusHistory []StatusRecord `json:"status_history,omitempty"`
+	CreatedAt     time.Time      `json:"created_at,omitempty"`
+	UpdatedAt     time.Time      `json:"updated_at,omitempty"`
+}
+
+// NewDomainResponse 域名记录转换为响应
+func NewDomainResponse(d XLDomain) DomainResponse {
+	return DomainResponse{
+		ID:         d.ID,
+		DomainName: d.Name,
+		Type:       d.Type,
+		Status:     d.Status,
+		Owner:      d.Owner,
+		CNAME:      d.CNAME,
+		Vendors:    d.ActiveVendors,
+		Revision:   d.Revision,
+		Config:     d.ConfigSnapshot().Redacted(),
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
 }
 
 // CreateTaskResponse 创建任务响应结构体
@@ -35,9 +60,34 @@ type CreateTaskResponse struct {
 	TaskID  string `json:"task_id"`
 	Domain  string `json:"domain"`
 	Status  string `json:"status"`
+	Outcome string `json:"outcome"`                   // created | existing | queued | superseded
+	Related string `json:"related_task_id,omitempty"` // 排队等待或被覆盖的已有任务
 	Message string `json:"message,omitempty"`
 }
 
+// VendorPlanResponse vendor 选择预览
+type VendorPlanResponse struct {
+	Domain    string           `json:"domain"`
+	Vendors   []string         `json:"vendors"` // 选中的 vendor
+	Decisions []VendorDecision `json:"decisions"`
+}
+
+// RevisionDiffResponse 两个配置版本的差异
+type RevisionDiffResponse struct {
+	DomainID string         `json:"domain_id"`
+	From     int            `json:"from"`
+	To       int            `json:"to"`
+	Changes  []ConfigChange `json:"changes"`
+}
+
+// 提交任务的结果
+const (
+	OutcomeCreated    = "created"
+	OutcomeExisting   = "existing"
+	OutcomeQueued     = "queued"
+	OutcomeSuperseded = "superseded"
+)
+
 // 响应码常量
 const (
 	CodeSuccess      = 200
@@ -45,6 +95,7 @@ const (
 	CodeUnauthorized = 401
 	CodeForbidden    = 403
 	CodeNotFound     = 404
+	CodeConflict     = 409
 	CodeServerError  = 500
 )
 
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// RevisionAction 产生配置版本的操作
+type RevisionAction string
+
+const (
+	RevisionCreate   RevisionAction = "create"
+	RevisionUpdate   RevisionAction = "update"
+	RevisionRollback RevisionAction = "rollback"
+)
+
+// DomainRevision 域名配置的不可变版本, 每次配置变更生成一个
+type DomainRevision struct {
+	ID       string         `json:"id" bson:"_id"`
+	DomainID string         `json:"domain_id" bson:"domain_id"`
+	Domain   string         `json:"domain" bson:"domain"`
+	Revision int            `json:"revision" bson:"revision"`
+	Action   RevisionAction `json:"action" bson:"action"`
+	// 回滚时的来源版本
+	SourceRevision int    `json:"source_revision,omitempty" bson:"source_revision,omitempty"`
+	Author         string `json:"author" bson:"author"`
+	RequestID      string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	TaskID         string `json:"task_id" bson:"task_id"`
+	// 完整的配置快照, 见 XLDomain.ConfigSnapshot
+	Snapshot  XLDomain  `json:"snapshot" bson:"snapshot"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// 配置差异类型
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// ConfigChange 两个配置间的一处差异
+type ConfigChange struct {
+	Path string      `json:"path"` // json 路径, 如 cache.rules[0].ttl
+	Op   string      `json:"op"`   // added, removed, changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// 密钥在差异中的掩码, 变化后的值用不同的掩码体现
+const (
+	secretMask        = "******"
+	secretMaskChanged = "****** (changed)"
+)
+
+/*
+DiffConfig 比较两个配置快照, 按 json 结构逐字段比较
+
+	对象按字段比较, 数组按下标比较
+	一方缺少的字段记为 added/removed, 密钥类字段只标记变化不显示值
+*/
+func DiffConfig(from, to XLDomain) ([]ConfigChange, error) {
+	from, to = redactForDiff(from.ConfigSnapshot(), to.ConfigSnapshot())
+	a, err := toJSONValue(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toJSONValue(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []ConfigChange{}
+	diffValue("", a, b, &changes)
+	return changes, nil
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+func diffValue(path string, a, b interface{}, changes *[]ConfigChange) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*changes = append(*changes, ConfigChange{Path: path, Op: ChangeAdded, New: b})
+		return
+	case b == nil:
+		*changes = append(*changes, ConfigChange{Path: path, Op: ChangeRemoved, Old: a})
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffValue(child, av[k], bv[k], changes)
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var x, y interface{}
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			diffValue(fmt.Sprintf("%s[%d]", path, i), x, y, changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, ConfigChange{Path: path, Op: ChangeChanged, Old: a, New: b})
+	}
+}
+
+// redactForDiff 去掉两个配置中的密钥, 密钥不同时 to 使用 secretMaskChanged
+func redactForDiff(from, to XLDomain) (XLDomain, XLDomain) {
+	f, t := from.Redacted(), to.Redacted()
+	if from.HTTPS != nil && to.HTTPS != nil && to.HTTPS.PrivateKey != "" && from.HTTPS.PrivateKey != to.HTTPS.PrivateKey {
+		t.HTTPS.PrivateKey = secretMaskChanged
+	}
+	if from.Access != nil && from.Access.Auth != nil && to.Access != nil && to.Access.Auth != nil {
+		fa, ta := from.Access.Auth, to.Access.Auth
+		if fa.PrimaryKey != ta.PrimaryKey {
+			t.Access.Auth.PrimaryKey = secretMaskChanged
+		}
+		if ta.BackupKey != "" && fa.BackupKey != ta.BackupKey {
+			t.Access.Auth.BackupKey = secretMaskChanged
+		}
+	}
+	return f, t
+}
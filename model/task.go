@@ -0,0 +1,134 @@
+package model
+
+import "time"
+
+// TaskState 任务整体状态
+type TaskState string
+
+const (
+	TaskPending   TaskState = "pending"
+	TaskRunning   TaskState = "running"
+	TaskSucceeded TaskState = "succeeded"
+	TaskFailed    TaskState = "failed"
+	TaskPaused    TaskState = "paused"
+	TaskCancelled TaskState = "cancelled"
+)
+
+// TaskControl 对任务的控制请求, 由执行中的 worker 响应
+type TaskControl string
+
+const (
+	ControlNone   TaskControl = ""
+	ControlPause  TaskControl = "pause"
+	ControlCancel TaskControl = "cancel"
+)
+
+// StepStatus 任务中单个步骤的状态
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	// 任务失败后执行补偿的结果
+	StepCompensated        StepStatus = "compensated"
+	StepCompensationFailed StepStatus = "compensation_failed"
+)
+
+// 任务类型
+const (
+	TaskTypeCreateDomain  = "create_domain"
+	TaskTypeUpdateDomain  = "update_domain" // 更新配置或回滚到历史版本
+	TaskTypeDeleteDomain  = "delete_domain"
+	TaskTypeEnableDomain  = "enable_domain"
+	TaskTypeDisableDomain = "disable_domain"
+	TaskTypeSuspendDomain = "suspend_domain" // 所有权验证失效后停用
+)
+
+// Task 持久化的工作流任务
+type Task struct {
+	ID     string    `json:"id" bson:"_id"`
+	Type   string    `json:"type" bson:"type"`
+	Domain string    `json:"domain" bson:"domain"`
+	Owner  string    `json:"owner" bson:"owner"`
+	State  TaskState `json:"state" bson:"state"`
+	Input  XLDomain  `json:"input" bson:"input"`
+	// 提交者及请求ID, 记录到配置版本中
+	Actor     string `json:"actor,omitempty" bson:"actor,omitempty"`
+	RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	// 回滚任务的来源版本
+	SourceRevision int `json:"source_revision,omitempty" bson:"source_revision,omitempty"`
+	// PATCH 提交的 JSON Merge Patch, 执行时应用到当前配置, 排队的多个修改依次生效
+	Patch string `json:"patch,omitempty" bson:"patch,omitempty"`
+	// 客户端提交的幂等键, 相同键的重复提交返回同一任务
+	IdempotencyKey string `json:"idempotency_key,omitempty" bson:"idempotency_key,omitempty"`
+	// 排队等待该任务结束后才执行
+	WaitFor string `json:"wait_for,omitempty" bson:"wait_for,omitempty"`
+	// 覆盖关系
+	Supersedes   string `json:"supersedes,omitempty" bson:"supersedes,omitempty"`
+	SupersededBy string `json:"superseded_by,omitempty" bson:"superseded_by,omitempty"`
+	// 待响应的控制请求
+	Control TaskControl `json:"control,omitempty" bson:"control,omitempty"`
+	// 执行租约, 持有实例定期续约, 过期后可被其他实例接管
+	LeaseOwner    string    `json:"lease_owner,omitempty" bson:"lease_owner"`
+	LeaseExpireAt time.Time `json:"lease_expire_at,omitempty" bson:"lease_expire_at,omitempty"`
+	// 步骤产出, 如 cname
+	Output map[string]string `json:"output,omitempty" bson:"output,omitempty"`
+	Steps  []TaskStep        `json:"steps" bson:"steps"`
+	// vendor 选择结果, 包括未选中的 vendor 及原因
+	VendorSelection []VendorDecision `json:"vendor_selection,omitempty" bson:"vendor_selection,omitempty"`
+	// 各 vendor 上的执行结果
+	VendorResults []VendorResult `json:"vendor_results,omitempty" bson:"vendor_results,omitempty"`
+	// 补偿尝试历史
+	Compensations []CompensationRecord `json:"compensations,omitempty" bson:"compensations,omitempty"`
+	Error         string               `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt     time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// TaskStep 任务中单个步骤的执行记录
+type TaskStep struct {
+	Name     string     `json:"name" bson:"name"`
+	Status   StepStatus `json:"status" bson:"status"`
+	Attempts int        `json:"attempts" bson:"attempts"`
+	// 幂等键, 重跑时已成功且键相同的步骤跳过
+	IdempotencyKey string    `json:"idempotency_key,omitempty" bson:"idempotency_key,omitempty"`
+	Error          string    `json:"error,omitempty" bson:"error,omitempty"`
+	StartedAt      time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt     time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// CompensationRecord 一次补偿尝试
+type CompensationRecord struct {
+	Step       string     `json:"step" bson:"step"`
+	Attempt    int        `json:"attempt" bson:"attempt"`
+	Status     StepStatus `json:"status" bson:"status"`
+	Error      string     `json:"error,omitempty" bson:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at" bson:"started_at"`
+	FinishedAt time.Time  `json:"finished_at" bson:"finished_at"`
+}
+
+// IsValid 是否为已定义的任务状态
+func (s TaskState) IsValid() bool {
+	switch s {
+	case TaskPending, TaskRunning, TaskSucceeded, TaskFailed, TaskPaused, TaskCancelled:
+		return true
+	}
+	return false
+}
+
+// IsTerminal 任务是否已结束
+func (s TaskState) IsTerminal() bool {
+	return s == TaskSucceeded || s == TaskFailed || s == TaskCancelled
+}
+
+// Step 按名称查找步骤记录, 不存在时返回nil
+func (t *Task) Step(name string) *TaskStep {
+	for i := range t.Steps {
+		if t.Steps[i].Name == name {
+			return &t.Steps[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package model
+
+import "time"
+
+// VendorDomainStatus 域名在 CDN vendor 上的状态
+type VendorDomainStatus string
+
+const (
+	VendorDomainConfiguring VendorDomainStatus = "configuring" // 配置下发中
+	VendorDomainOnline      VendorDomainStatus = "online"
+	VendorDomainOffline     VendorDomainStatus = "offline" // 已停用
+	VendorDomainFailed      VendorDomainStatus = "failed"
+	VendorDomainDeleting    VendorDomainStatus = "deleting"
+)
+
+// VendorDomainRequest 在 vendor 上创建/更新域名
+type VendorDomainRequest struct {
+	Domain XLDomain `json:"domain"`
+	// 透传给 vendor 的幂等键, 重试时不会重复创建
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// VendorDomain vendor 上的域名信息
+type VendorDomain struct {
+	Vendor    string             `json:"vendor" bson:"vendor"`
+	Domain    string             `json:"domain" bson:"domain"`
+	CNAME     string             `json:"cname" bson:"cname"` // vendor 分配的 CNAME
+	Status    VendorDomainStatus `json:"status" bson:"status"`
+	CreatedAt time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// PurgeRequest 刷新缓存
+type PurgeRequest struct {
+	Domain string   `json:"domain"`
+	URLs   []string `json:"urls,omitempty"`
+	Dirs   []string `json:"dirs,omitempty"`
+}
+
+// PrefetchRequest 预热
+type PrefetchRequest struct {
+	Domain string   `json:"domain"`
+	URLs   []string `json:"urls"`
+}
+
+// VendorTaskResult vendor 侧异步任务(刷新/预热)的提交结果
+type VendorTaskResult struct {
+	Vendor string `json:"vendor"`
+	TaskID string `json:"task_id"`
+}
+
+// VendorCapability vendor 支持的功能, 用于选择 vendor
+type VendorCapability string
+
+const (
+	CapabilityHTTPS    VendorCapability = "https"
+	CapabilityHTTP2    VendorCapability = "http2"
+	CapabilityQUIC     VendorCapability = "quic"
+	CapabilityIPv6     VendorCapability = "ipv6"
+	CapabilityWildcard VendorCapability = "wildcard" // 泛域名
+)
+
+// IsValid 是否为已定义的功能
+func (c VendorCapability) IsValid() bool {
+	switch c {
+	case CapabilityHTTPS, CapabilityHTTP2, CapabilityQUIC, CapabilityIPv6, CapabilityWildcard:
+		return true
+	}
+	return false
+}
+
+// VendorDecision vendor 选择结果及原因
+type VendorDecision struct {
+	Vendor   string `json:"vendor" bson:"vendor"`
+	Selected bool   `json:"selected" bson:"selected"`
+	Weight   int    `json:"weight" bson:"weight"`
+	Reason   string `json:"reason" bson:"reason"`
+}
+
+// VendorResult 在单个 vendor 上的操作结果
+type VendorResult struct {
+	Vendor    string             `json:"vendor" bson:"vendor"`
+	Success   bool               `json:"success" bson:"success"`
+	CNAME     string             `json:"cname,omitempty" bson:"cname,omitempty"`
+	Status    VendorDomainStatus `json:"status,omitempty" bson:"status,omitempty"`
+	ErrorKind string             `json:"error_kind,omitempty" bson:"error_kind,omitempty"`
+	Error     string             `json:"error,omitempty" bson:"error,omitempty"`
+	LatencyMs int64              `json:"latency_ms" bson:"latency_ms"`
+	Attempts  int                `json:"attempts" bson:"attempts"`
+	// 由本任务创建; 已存在而沿用的为 false, 补偿时不删除
+	Created   bool      `json:"created,omitempty" bson:"created,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// VendorEvent vendor 回调推送的域名状态变更
+type VendorEvent struct {
+	ID         string             `json:"id" bson:"_id"`
+	Vendor     string             `json:"vendor" bson:"vendor"`
+	Domain     string             `json:"domain" bson:"domain"`
+	Status     VendorDomainStatus `json:"status" bson:"status"`
+	CNAME      string             `json:"cname,omitempty" bson:"cname,omitempty"`
+	Message    string             `json:"message,omitempty" bson:"message,omitempty"`
+	ReceivedAt time.Time          `json:"received_at" bson:"received_at"`
+}
+
+// IsTerminal 域名在 vendor 上已上线或失败, 不再需要等待
+func (s VendorDomainStatus) IsTerminal() bool {
+	return s == VendorDomainOnline || s == VendorDomainFailed
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"centralHub/config"
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+)
+
+/*
+cname 规则: <域名前缀>-<hash>[.www].<suffix>
+
+	域名前缀: 域名去掉泛域名标记, 转小写, "." 替换为 "-", 过长时截断
+	hash: sha256(域名[#序号]) 前 8 位, 同一域名每次生成相同的 cname, 冲突时递增序号
+	泛域名多一层 www
+
+dnspod 限制:
+
+	主机记录(subdomain)长度不超过 50
+	最低用户权限不支持 subdomain 层次超过 3 层, 这里普通域名 1 层, 泛域名 2 层
+	每层只能包含字母、数字、"-", 不能以 "-" 开头或结尾
+*/
+const (
+	dnspodMaxSubdomainLen = 50
+	cnameHashLen          = 8
+	wildcardLabel         = "www"
+	// hash 冲突时的最大尝试次数
+	maxCNAMEAttempts = 8
+)
+
+// ErrCNAMEExhausted 多次尝试后仍无可用 cname
+var ErrCNAMEExhausted = errors.New("no available cname")
+
+// CNAMEService cname 分配, 分配结果保存在 store 中保证唯一
+type CNAMEService struct {
+	store *store.CNAMEStore
+	cfg   config.CNAMEConfig
+}
+
+func NewCNAMEService(cs *store.CNAMEStore, cfg config.CNAMEConfig) *CNAMEService {
+	return &CNAMEService{store: cs, cfg: cfg}
+}
+
+// SuffixFor 租户使用的 cname 后缀, 未单独配置时使用默认后缀
+func (s *CNAMEService) SuffixFor(owner string) string {
+	if suffix, ok := s.cfg.TenantSuffixes[owner]; ok && suffix != "" {
+		return suffix
+	}
+	return s.cfg.Suffix
+}
+
+// Allocate 为域名分配 cname, 域名已分配过时返回已有的 cname
+func (s *CNAMEService) Allocate(ctx context.Context, obj model.XLDomain, taskID string) (*model.CNAMEReservation, error) {
+	existing, err := s.store.FindByDomain(ctx, obj.Name)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	zone := s.SuffixFor(obj.Owner)
+	for attempt := 0; attempt < maxCNAMEAttempts; attempt++ {
+		sub := BuildCNAMESubDomain(obj.Name, attempt)
+		if err := ValidateSubDomain(sub); err != nil {
+			return nil, err
+		}
+		r := model.CNAMEReservation{
+			CNAME:     sub + "." + zone,
+			Domain:    obj.Name,
+			Owner:     obj.Owner,
+			Zone:      zone,
+			SubDomain: sub,
+			TaskID:    taskID,
+			CreatedAt: time.Now(),
+		}
+		err := s.store.Reserve(ctx, r)
+		if err == nil {
+			return &r, nil
+		}
+		if !errors.Is(err, store.ErrDuplicateKey) {
+			return nil, err
+		}
+		// 并发分配同一域名时沿用先写入的记录, 否则是 hash 冲突, 换下一个序号
+		if existing, ferr := s.store.FindByDomain(ctx, obj.Name); ferr != nil || existing != nil {
+			return existing, ferr
+		}
+		logger.RunLogger.Warn().Str("domain", obj.Name).Str("cname", r.CNAME).Msg("Cname collision, retry")
+	}
+	return nil, fmt.Errorf("%w for %s", ErrCNAMEExhausted, obj.Name)
+}
+
+// Release 释放域名的 cname
+func (s *CNAMEService) Release(ctx context.Context, r *model.CNAMEReservation) error {
+	return s.store.Release(ctx, r.CNAME, r.Domain)
+}
+
+// Get 域名已分配的 cname, 不存在时返回 (nil, nil)
+func (s *CNAMEService) Get(ctx context.Context, domain string) (*model.CNAMEReservation, error) {
+	return s.store.FindByDomain(ctx, domain)
+}
+
+// BuildCNAMESubDomain 生成 zone 下的主机记录, 同一域名及序号结果相同
+func BuildCNAMESubDomain(domain string, attempt int) string {
+	name := strings.ToLower(domain)
+	wildcard := strings.HasPrefix(name, "*.") || strings.HasPrefix(name, ".")
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "*"), ".")
+
+	seed := name
+	if attempt > 0 {
+		seed += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	hash := hex.EncodeToString(sum[:])[:cnameHashLen]
+
+	maxPrefix := dnspodMaxSubdomainLen - len(hash) - 1
+	if wildcard {
+		maxPrefix -= len(wildcardLabel) + 1
+	}
+	prefix := sanitizeLabel(name)
+	if len(prefix) > maxPrefix {
+		prefix = strings.TrimRight(prefix[:maxPrefix], "-")
+	}
+
+	sub := hash
+	if prefix != "" {
+		sub = prefix + "-" + hash
+	}
+	if wildcard {
+		sub += "." + wildcardLabel
+	}
+	return sub
+}
+
+// ValidateSubDomain 按 dnspod 规则检查主机记录
+func ValidateSubDomain(sub string) error {
+	if len(sub) == 0 || len(sub) > dnspodMaxSubdomainLen {
+		return fmt.Errorf("cname subdomain length must be 1-%d: %s", dnspodMaxSubdomainLen, sub)
+	}
+	labels := strings.Split(sub, ".")
+	if len(labels) > 2 {
+		return fmt.Errorf("cname subdomain has too many levels: %s", sub)
+	}
+	for _, label := range labels {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid cname subdomain label %q", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("invalid character %q in cname subdomain", c)
+			}
+		}
+	}
+	return nil
+}
+
+// sanitizeLabel 转换为单层合法标签: "." 和非法字符替换为 "-", 合并连续的 "-"
+func sanitizeLabel(name string) string {
+	var b strings.Builder
+	lastHyphen := true // 去掉开头的 "-"
+	for _, c := range name {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			b.WriteRune(c)
+			lastHyphen = false
+			continue
+		}
+		if !lastHyphen {
+			b.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
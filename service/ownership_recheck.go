@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+/*
+所有权复查
+
+	已通过的挑战每 recheck_interval 复查一次证明(TXT 记录、文件或 cname), 不校验 token 有效期
+	确定未找到证明(NXDOMAIN、没有记录、记录不匹配、404 等)才算失效, 超时、SERVFAIL、连接失败、5xx 只记录结果
+	首次未找到证明时记录 lost_at 并通知所有者, 宽限期(recheck_grace)内再次找到则清除并通知恢复
+	超过宽限期仍未找到时停用所有者名下的该域名及其泛域名, 每 5 分钟复查并重新提交失败的停用任务,
+	全部停用(suspended)后挑战变为 revoked, 需重新验证后才能启用
+	通知写入日志, 配置了 notify_url 时同时 POST 事件
+*/
+const (
+	// 扫描到期复查的间隔
+	recheckScanInterval = time.Minute
+	// 每次扫描最多复查的挑战数, 其余留到下次扫描
+	maxRechecksPerScan = 100
+	// 超过宽限期后, 等待域名全部停用期间的复查间隔
+	suspendRecheckInterval = 5 * time.Minute
+)
+
+// DomainSuspender 停用所有权失效的域名, 由 workflow 提交停用任务
+type DomainSuspender interface {
+	// SuspendDomains 停用 owner 名下的 domain 及 *.domain, 已有进行中的停用任务时不重复提交
+	// 返回是否已全部停用及相关的任务ID
+	SuspendDomains(ctx context.Context, domain, owner string) (bool, []string, error)
+}
+
+// StartRecheck 启动后台复查, 多副本下每个挑战由领取到的实例复查
+func (s *OwnershipService) StartRecheck(ctx context.Context, suspender DomainSuspender) {
+	go func() {
+		ticker := time.NewTicker(recheckScanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.recheckDue(ctx, suspender)
+			}
+		}
+	}()
+	logger.RunLogger.Info().Dur("interval", s.recheckInterval).Dur("grace", s.grace).Msg("Ownership recheck started")
+}
+
+// recheckDue 复查上次复查早于 recheck_interval 的挑战
+func (s *OwnershipService) recheckDue(ctx context.Context, suspender DomainSuspender) {
+	now := time.Now()
+	before, lostBefore, retryBefore := now.Add(-s.recheckInterval), now.Add(-s.grace), now.Add(-suspendRecheckInterval)
+	for i := 0; i < maxRechecksPerScan; i++ {
+		ch, err := s.store.ClaimRecheck(ctx, before, lostBefore, retryBefore)
+		if err != nil || ch == nil {
+			return
+		}
+		s.recheck(ctx, ch, suspender)
+	}
+}
+
+func (s *OwnershipService) recheck(ctx context.Context, ch *model.OwnershipChallenge, suspender DomainSuspender) {
+	rlog := logger.RunLogger.With().Str("req_id", ch.ID).Str("domain", ch.Domain).Str("owner", ch.Owner).Logger()
+
+	check, err := s.probe(ctx, ch)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Ownership recheck failed")
+		return
+	}
+	event := model.OwnershipEvent{Domain: ch.Domain, Owner: ch.Owner, ReqID: ch.ID, VerifyType: ch.Method, LostAt: ch.LostAt}
+	now := time.Now()
+
+	switch {
+	case check.Verified:
+		if err := s.store.SaveRecheck(ctx, ch.ID, check, time.Time{}); err != nil || ch.LostAt.IsZero() {
+			return
+		}
+		rlog.Info().Time("lost_at", ch.LostAt).Msg("Ownership proof restored")
+		event.Event = model.OwnershipRestored
+		s.notify(ctx, event)
+
+	case check.Inconclusive:
+		// 查询失败不能说明证明已消失, 不开始也不推进失效计时, 已超过宽限期时同样不停用
+		rlog.Warn().Interface("answers", check.Answers).Interface("fetches", check.Fetches).Msg("Ownership recheck inconclusive")
+		_ = s.store.SaveRecheck(ctx, ch.ID, check, ch.LostAt)
+
+	case ch.LostAt.IsZero():
+		if err := s.store.SaveRecheck(ctx, ch.ID, check, now); err != nil {
+			return
+		}
+		rlog.Warn().Interface("answers", check.Answers).Interface("fetches", check.Fetches).Msg("Ownership proof lost")
+		event.Event = model.OwnershipLost
+		event.LostAt = now
+		event.SuspendAt = now.Add(s.grace)
+		event.Message = ch.MissingProof()
+		s.notify(ctx, event)
+
+	case now.Sub(ch.LostAt) < s.grace:
+		_ = s.store.SaveRecheck(ctx, ch.ID, check, ch.LostAt)
+
+	default:
+		// 全部停用前保持 verified, 每 suspendRecheckInterval 复查并重新提交失败的停用任务
+		done, taskIDs, err := suspender.SuspendDomains(ctx, ch.Domain, ch.Owner)
+		switch {
+		case err != nil:
+			rlog.Error().Err(err).Msg("Failed to suspend domains after ownership lost")
+			_ = s.store.SaveRecheck(ctx, ch.ID, check, ch.LostAt)
+			return
+		case !done:
+			rlog.Info().Strs("task_ids", taskIDs).Msg("Waiting for domains to be suspended")
+			_ = s.store.SaveRecheck(ctx, ch.ID, check, ch.LostAt)
+			return
+		}
+		reason := ch.MissingProof() + " since " + ch.LostAt.Format(time.RFC3339)
+		if err := s.store.Revoke(ctx, ch.ID, reason, check, now.Add(s.retention)); err != nil {
+			return
+		}
+		rlog.Warn().Strs("task_ids", taskIDs).Msg("Ownership revoked, domains suspended")
+		event.Event = model.OwnershipRevoked
+		event.TaskIDs = taskIDs
+		event.Message = reason
+		s.notify(ctx, event)
+	}
+}
+
+// notify 记录事件, 配置了 notify_url 时推送给所有者, 推送失败只记录日志
+func (s *OwnershipService) notify(ctx context.Context, event model.OwnershipEvent) {
+	rlog := logger.RunLogger.With().Str("event", event.Event).Str("domain", event.Domain).Str("owner", event.Owner).Logger()
+	rlog.Info().Interface("payload", event).Msg("Ownership event")
+	if s.notifyURL == "" {
+		return
+	}
+	resp, err := s.notifier.Post(ctx, s.notifyURL, event, nil)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed to send ownership event")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		rlog.Warn().Int("status", resp.StatusCode).Msg("Ownership event rejected")
+	}
+}
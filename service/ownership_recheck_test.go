@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"centralHub/model"
+)
+
+const testRecheckGrace = 72 * time.Hour
+
+// fakeRecheckStore 记录复查写入的结果, 其他方法未实现
+type fakeRecheckStore struct {
+	challengeStore
+	saved   []time.Time // 每次 SaveRecheck 的 lostAt
+	revoked []string
+}
+
+func (f *fakeRecheckStore) SaveRecheck(_ context.Context, _ string, _ *model.OwnershipCheck, lostAt time.Time) error {
+	f.saved = append(f.saved, lostAt)
+	return nil
+}
+
+func (f *fakeRecheckStore) Revoke(_ context.Context, id, _ string, _ *model.OwnershipCheck, _ time.Time) error {
+	f.revoked = append(f.revoked, id)
+	return nil
+}
+
+// fakeSuspender 按配置返回停用结果
+type fakeSuspender struct {
+	done  bool
+	err   error
+	calls int
+}
+
+func (f *fakeSuspender) SuspendDomains(context.Context, string, string) (bool, []string, error) {
+	f.calls++
+	return f.done, []string{"task-1"}, f.err
+}
+
+// eventRecorder 接收 notify_url 推送的事件
+func eventRecorder(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e model.OwnershipEvent
+		_ = json.NewDecoder(r.Body).Decode(&e)
+		mu.Lock()
+		events = append(events, e.Event)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+}
+
+func TestRecheck(t *testing.T) {
+	const (
+		proofPresent = "present"
+		proofMissing = "missing"
+		proofUnknown = "servfail"
+	)
+	var (
+		notLost     = time.Duration(0)
+		inGrace     = time.Hour
+		pastGrace   = testRecheckGrace + time.Hour
+		suspendErr  = errors.New("mongo down")
+		unchanged   = "unchanged"
+		cleared     = "cleared"
+		startedLost = "started"
+	)
+	cases := []struct {
+		name      string
+		proof     string
+		lostFor   time.Duration // lost_at 距今多久, 0 表示未失效
+		suspender fakeSuspender
+		lostAt    string // SaveRecheck 写入的 lost_at, 空表示未调用
+		suspended bool
+		revoked   bool
+		events    []string
+	}{
+		{name: "proof present", proof: proofPresent, lostFor: notLost, lostAt: cleared},
+		{name: "proof restored in grace", proof: proofPresent, lostFor: inGrace, lostAt: cleared, events: []string{model.OwnershipRestored}},
+		{name: "proof lost", proof: proofMissing, lostFor: notLost, lostAt: startedLost, events: []string{model.OwnershipLost}},
+		{name: "still lost in grace", proof: proofMissing, lostFor: inGrace, lostAt: unchanged},
+		{name: "grace passed, all suspended", proof: proofMissing, lostFor: pastGrace,
+			suspender: fakeSuspender{done: true}, suspended: true, revoked: true, events: []string{model.OwnershipRevoked}},
+		{name: "grace passed, suspension in progress", proof: proofMissing, lostFor: pastGrace,
+			suspender: fakeSuspender{done: false}, lostAt: unchanged, suspended: true},
+		{name: "grace passed, suspension failed", proof: proofMissing, lostFor: pastGrace,
+			suspender: fakeSuspender{err: suspendErr}, lostAt: unchanged, suspended: true},
+		{name: "resolver error, not lost", proof: proofUnknown, lostFor: notLost, lostAt: cleared},
+		{name: "resolver error in grace", proof: proofUnknown, lostFor: inGrace, lostAt: unchanged},
+		{name: "resolver error past grace", proof: proofUnknown, lostFor: pastGrace,
+			suspender: fakeSuspender{done: true}, lostAt: unchanged},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dns := startFakeDNS(t)
+			notify, events := eventRecorder(t)
+			st := &fakeRecheckStore{}
+			s := newTestOwnershipService(dns.Addr(), 3600)
+			s.store, s.grace, s.notifyURL = st, testRecheckGrace, notify.URL
+
+			ch, err := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+			if err != nil {
+				t.Fatalf("NewChallenge: %v", err)
+			}
+			ch.Status = model.ChallengeVerified
+			if tc.lostFor > 0 {
+				ch.LostAt = time.Now().Add(-tc.lostFor)
+			}
+			switch tc.proof {
+			case proofPresent:
+				dns.SetTXT(ch.RecordName, ch.Value)
+			case proofUnknown:
+				dns.SetServFail()
+			}
+			suspender := tc.suspender
+
+			s.recheck(context.Background(), ch, &suspender)
+
+			if got := suspender.calls > 0; got != tc.suspended {
+				t.Errorf("suspend called = %v, want %v", got, tc.suspended)
+			}
+			if got := len(st.revoked) > 0; got != tc.revoked {
+				t.Errorf("revoked = %v, want %v", got, tc.revoked)
+			}
+			var lostAt string
+			if len(st.saved) > 1 {
+				t.Fatalf("SaveRecheck called %d times", len(st.saved))
+			}
+			if len(st.saved) == 1 {
+				switch saved := st.saved[0]; {
+				case saved.IsZero():
+					lostAt = cleared
+				case saved.Equal(ch.LostAt):
+					lostAt = unchanged
+				case ch.LostAt.IsZero() && time.Since(saved) < time.Minute:
+					lostAt = startedLost
+				default:
+					lostAt = saved.String()
+				}
+			}
+			if lostAt != tc.lostAt {
+				t.Errorf("saved lost_at = %q, want %q", lostAt, tc.lostAt)
+			}
+			if got := events(); len(got) != len(tc.events) || (len(got) > 0 && got[0] != tc.events[0]) {
+				t.Errorf("events = %v, want %v", got, tc.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"centralHub/client"
+	"centralHub/config"
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+)
+
+/*
+所有权验证挑战
+
+	token: <过期时间 unix 秒>.<随机串>.<签名>
+	签名: base64url(HMAC-SHA256(secret, 域名 \n 所有者 \n 过期时间 \n 随机串))
+	token 绑定域名、所有者及过期时间, 防止挑战记录被篡改或跨域名使用
+	挑战保存在 store 中, 以 req_id 提交验证; 过期或检查次数用完仍未通过为 failed, 未通过的挑战保留一段时间后删除
+	DNS 验证: 在 _centralhub-challenge.<域名> 添加 TXT 记录 centralhub-verification=<token>
+	文件验证: 在 http(s)://<域名>/.well-known/centralhub/<token>.txt 放置内容为 centralhub-verification=<token> 的文件
+		http 或 https 任一返回 200 且内容一致即通过, 只跟随同一域名的重定向
+		只连接公网地址, 域名解析到内网、回环或链路本地地址时不发起请求
+	cname 验证: 添加 cname 记录 centralhub-<hash>.<域名> -> <hash>.<cname_zone>, hash 由 token 派生
+*/
+const (
+	challengeLabel       = "_centralhub-challenge"
+	challengeValuePrefix = "centralhub-verification="
+	challengeFileDir     = "/.well-known/centralhub/"
+	challengeCNAMEPrefix = "centralhub-"
+	tokenNonceBytes      = 12
+
+	// 验证文件最多跟随的重定向次数
+	maxFileRedirects = 3
+	// 验证文件内容上限, 超过时视为失败
+	maxFileSize = 1024
+	// 失败时记录的响应内容长度
+	maxRecordedBody = 256
+
+	// DNS 服务器确定地回答记录不存在(NXDOMAIN 或没有该类型的记录)
+	answerNoRecord = "no record"
+	// 响应内容读取失败, 与网络错误一样不是确定的结果
+	fetchReadFailed = "read body failed"
+	// 域名解析到非公网地址, 站点不可能对外提供验证文件, 是确定的结果
+	fetchAddrNotAllowed = "address not allowed"
+)
+
+var (
+	// ErrInvalidChallengeToken token 格式错误或签名不匹配(被篡改, 或不属于该域名/所有者)
+	ErrInvalidChallengeToken = errors.New("invalid challenge token")
+	// ErrChallengeExpired token 已过期, 需重新发起验证
+	ErrChallengeExpired = errors.New("challenge expired")
+	// ErrChallengeNotFound req_id 不存在或已被删除
+	ErrChallengeNotFound = errors.New("challenge not found")
+	// ErrChallengeDomainMismatch req_id 不属于提交的域名
+	ErrChallengeDomainMismatch = errors.New("challenge does not belong to domain")
+
+	// errAddrNotAllowed 获取验证文件时拒绝连接的地址
+	errAddrNotAllowed = errors.New(fetchAddrNotAllowed)
+)
+
+// challengeStore 挑战的存储, 由 store.ChallengeStore 实现
+type challengeStore interface {
+	Insert(ctx context.Context, ch model.OwnershipChallenge) error
+	FindByID(ctx context.Context, id string) (*model.OwnershipChallenge, error)
+	ClaimAttempt(ctx context.Context, id string) (*model.OwnershipChallenge, error)
+	Finish(ctx context.Context, id, status, reason string, check *model.OwnershipCheck) error
+	Supersede(ctx context.Context, domain, owner, keepID string, purgeAt time.Time) error
+	FindVerified(ctx context.Context, domain, owner string) (*model.OwnershipChallenge, error)
+	ClaimRecheck(ctx context.Context, before, lostBefore, retryBefore time.Time) (*model.OwnershipChallenge, error)
+	SaveRecheck(ctx context.Context, id string, check *model.OwnershipCheck, lostAt time.Time) error
+	Revoke(ctx context.Context, id, reason string, check *model.OwnershipCheck, purgeAt time.Time) error
+}
+
+// OwnershipService 域名所有权验证
+type OwnershipService struct {
+	store       challengeStore
+	secret      []byte
+	ttl         time.Duration
+	maxAttempts int
+	retention   time.Duration
+	resolvers   []string
+	timeout     time.Duration
+	http        *client.HTTPClient
+	cnameZone   string
+	// 获取验证文件时允许连接的地址, 默认只允许公网地址
+	dialAllowed func(netip.Addr) bool
+
+	// 复查
+	recheckInterval time.Duration
+	grace           time.Duration
+	notifyURL       string
+	notifier        *client.HTTPClient
+}
+
+func NewOwnershipService(cs *store.ChallengeStore, cfg config.OwnershipConfig) *OwnershipService {
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+		logger.RunLogger.Warn().Msg("Ownership secret not configured, using a random key; challenges will not survive restarts")
+	}
+	s := &OwnershipService{
+		store:       cs,
+		secret:      secret,
+		ttl:         time.Duration(cfg.TokenTTL) * time.Second,
+		maxAttempts: cfg.MaxAttempts,
+		retention:   time.Duration(cfg.Retention) * time.Second,
+		resolvers:   cfg.Resolvers,
+		timeout:     time.Duration(cfg.ResolveTimeout) * time.Second,
+		cnameZone:   cfg.CNAMEZone,
+		dialAllowed: isPublicAddr,
+
+		recheckInterval: time.Duration(cfg.RecheckInterval) * time.Second,
+		grace:           time.Duration(cfg.RecheckGrace) * time.Second,
+		notifyURL:       cfg.NotifyURL,
+		notifier:        client.NewHTTPClient(client.WithTimeout(time.Duration(cfg.HTTPTimeout) * time.Second)),
+	}
+	dialer := &net.Dialer{
+		Timeout: time.Duration(cfg.HTTPTimeout) * time.Second,
+		// 在解析之后、连接之前检查实际连接的地址, 包括每一跳重定向
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !s.dialAllowed(addr.Unmap()) {
+				return fmt.Errorf("%w: %s", errAddrNotAllowed, addr)
+			}
+			return nil
+		},
+	}
+	s.http = client.NewHTTPClient(
+		client.WithTimeout(time.Duration(cfg.HTTPTimeout)*time.Second),
+		// 文件内容即证明, 不要求证书有效(域名可能尚未配置证书)
+		// 不使用代理, 否则连接的是代理地址, 无法检查目标地址
+		client.WithTransport(&http.Transport{
+			DialContext:     dialer.DialContext,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}),
+		// 重定向由 fetchFile 逐跳检查
+		client.WithCheckRedirect(func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}),
+	)
+	return s
+}
+
+// isPublicAddr 是否为公网地址, 拒绝回环、内网、链路本地、组播及未指定地址
+func isPublicAddr(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddrSpace.Contains(addr)
+}
+
+// sharedAddrSpace 运营商级 NAT 地址(RFC 6598), 同样不是公网地址
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// ChallengeRecordName 域名的 TXT 验证记录名
+func ChallengeRecordName(domain string) string {
+	return challengeLabel + "." + domain
+}
+
+// ChallengeFilePath 验证文件的 URL 路径
+func ChallengeFilePath(token string) string {
+	return challengeFileDir + token + ".txt"
+}
+
+// CNAMERecord cname 验证的记录名及目标, 由 token 派生
+func (s *OwnershipService) CNAMERecord(domain, token string) (name, target string) {
+	sum := sha256.Sum256([]byte(token))
+	h := hex.EncodeToString(sum[:])
+	return challengeCNAMEPrefix + h[:12] + "." + domain, h[12:44] + "." + s.cnameZone
+}
+
+// NewChallenge 为域名及所有者生成验证挑战
+func (s *OwnershipService) NewChallenge(domain, owner string, method model.VerifyMethod) (*model.OwnershipChallenge, error) {
+	nonce := make([]byte, tokenNonceBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	expireAt := time.Now().Add(s.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expireAt.Unix(), 10)
+	n := base64.RawURLEncoding.EncodeToString(nonce)
+	token := exp + "." + n + "." + s.sign(domain, owner, exp, n)
+
+	challenge := &model.OwnershipChallenge{
+		Domain:   domain,
+		Owner:    owner,
+		Method:   method,
+		Token:    token,
+		Value:    challengeValuePrefix + token,
+		ExpireAt: expireAt,
+	}
+	switch method {
+	case model.VerifyDNS:
+		challenge.RecordName = ChallengeRecordName(domain)
+	case model.VerifyFile:
+		challenge.Path = ChallengeFilePath(token)
+	case model.VerifyCNAME:
+		challenge.RecordName, challenge.Value = s.CNAMERecord(domain, token)
+	}
+	return challenge, nil
+}
+
+// Issue 生成验证挑战并保存, 返回的 ID 即 req_id
+func (s *OwnershipService) Issue(ctx context.Context, domain, owner string, method model.VerifyMethod) (*model.OwnershipChallenge, error) {
+	ch, err := s.NewChallenge(domain, owner, method)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ch.ID = uuid.New().String()
+	ch.Status = model.ChallengePending
+	ch.MaxAttempts = s.maxAttempts
+	ch.CreatedAt = now
+	ch.UpdatedAt = now
+	ch.PurgeAt = ch.ExpireAt.Add(s.retention)
+	if err := s.store.Insert(ctx, *ch); err != nil {
+		return nil, err
+	}
+	logger.RunLogger.Info().Str("req_id", ch.ID).Str("domain", domain).Str("owner", owner).
+		Str("verify_type", string(method)).Msg("Ownership challenge saved")
+	return ch, nil
+}
+
+// Verify 检查 req_id 对应的挑战并记录结果, 返回检查后的挑战
+//
+//	挑战不属于 domain 时返回 ErrChallengeDomainMismatch; 已通过或已失败的挑战不再检查
+//	每次检查占用一次次数, 过期或次数用完仍未通过为 failed
+func (s *OwnershipService) Verify(ctx context.Context, id, domain string) (*model.OwnershipChallenge, error) {
+	ch, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, ErrChallengeNotFound
+	}
+	if ch.Domain != domain {
+		return nil, ErrChallengeDomainMismatch
+	}
+	if ch.Status != model.ChallengePending {
+		return ch, nil
+	}
+
+	claimed, err := s.store.ClaimAttempt(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if claimed == nil {
+		// 已过期或次数用完; 否则是并发的检查已结束该挑战
+		reason := ""
+		switch {
+		case !time.Now().Before(ch.ExpireAt):
+			reason = ErrChallengeExpired.Error()
+		case ch.Attempts >= ch.MaxAttempts:
+			reason = fmt.Sprintf("max attempts (%d) exceeded", ch.MaxAttempts)
+		}
+		if reason != "" {
+			if err := s.store.Finish(ctx, id, model.ChallengeFailed, reason, nil); err != nil {
+				return nil, err
+			}
+		}
+		return s.reload(ctx, id)
+	}
+
+	check, err := s.Check(ctx, claimed)
+	status, reason := model.ChallengePending, ""
+	switch {
+	case errors.Is(err, ErrChallengeExpired), errors.Is(err, ErrInvalidChallengeToken):
+		// 签名密钥变更后, 之前签发的挑战同样无法通过
+		status, reason = model.ChallengeFailed, err.Error()
+	case err != nil:
+		return nil, err
+	case check.Verified:
+		status = model.ChallengeVerified
+	case claimed.Attempts >= claimed.MaxAttempts:
+		status, reason = model.ChallengeFailed, fmt.Sprintf("max attempts (%d) exceeded", claimed.MaxAttempts)
+	}
+	if err := s.store.Finish(ctx, id, status, reason, check); err != nil {
+		return nil, err
+	}
+	logger.RunLogger.Info().Str("req_id", id).Str("domain", domain).Str("status", status).
+		Int("attempts", claimed.Attempts).Msg("Ownership challenge checked")
+
+	ch, err = s.reload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// 只复查最近通过的挑战, 之前的证明可以撤下
+	if ch.Status == model.ChallengeVerified {
+		if err := s.store.Supersede(ctx, ch.Domain, ch.Owner, id, time.Now().Add(s.retention)); err != nil {
+			return nil, err
+		}
+	}
+	return ch, nil
+}
+
+// Check 校验 token 后按挑战的验证方式检查一次, 不修改挑战
+func (s *OwnershipService) Check(ctx context.Context, ch *model.OwnershipChallenge) (*model.OwnershipCheck, error) {
+	if _, err := s.VerifyToken(ch.Domain, ch.Owner, ch.Token); err != nil {
+		return nil, err
+	}
+	return s.probe(ctx, ch)
+}
+
+// probe 按验证方式查询证明, 不校验 token; 复查时 token 已过期
+func (s *OwnershipService) probe(ctx context.Context, ch *model.OwnershipChallenge) (*model.OwnershipCheck, error) {
+	var check *model.OwnershipCheck
+	switch ch.Method {
+	case model.VerifyDNS:
+		check = s.checkDNS(ctx, ch.Domain, ch.Owner, ch.Token)
+	case model.VerifyFile:
+		check = s.checkFile(ctx, ch.Domain, ch.Owner, ch.Token)
+	case model.VerifyCNAME:
+		check = s.checkCNAME(ctx, ch.Domain, ch.Owner, ch.Token)
+	default:
+		return nil, fmt.Errorf("unsupported verify_type: %s", ch.Method)
+	}
+	check.CheckedAt = time.Now()
+	return check, nil
+}
+
+// Verified 域名及所有者是否有当前有效的验证, 复查发现证明消失后不再有效
+func (s *OwnershipService) Verified(ctx context.Context, domain, owner string) (bool, error) {
+	ch, err := s.store.FindVerified(ctx, domain, owner)
+	return ch != nil, err
+}
+
+func (s *OwnershipService) reload(ctx context.Context, id string) (*model.OwnershipChallenge, error) {
+	ch, err := s.store.FindByID(ctx, id)
+	if err == nil && ch == nil {
+		err = ErrChallengeNotFound
+	}
+	return ch, err
+}
+
+func (s *OwnershipService) sign(domain, owner, exp, nonce string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(domain + "\n" + owner + "\n" + exp + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyToken 校验 token 的签名及有效期, 返回过期时间
+func (s *OwnershipService) VerifyToken(domain, owner, token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, ErrInvalidChallengeToken
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidChallengeToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(domain, owner, parts[0], parts[1]))) {
+		return time.Time{}, ErrInvalidChallengeToken
+	}
+	expireAt := time.Unix(unix, 0)
+	if time.Now().After(expireAt) {
+		return expireAt, ErrChallengeExpired
+	}
+	return expireAt, nil
+}
+
+// checkDNS 查询 TXT 验证记录, 任一 DNS 服务器返回预期的值即验证通过
+func (s *OwnershipService) checkDNS(ctx context.Context, domain, owner, token string) *model.OwnershipCheck {
+	want := challengeValuePrefix + token
+	answers := s.lookup(ctx, ChallengeRecordName(domain), (*net.Resolver).LookupTXT)
+
+	check := &model.OwnershipCheck{Answers: answers}
+	for _, a := range answers {
+		for _, r := range a.Records {
+			if strings.TrimSpace(r) == want {
+				check.Verified = true
+			}
+		}
+	}
+	check.Inconclusive = !check.Verified && !anyDefiniteAnswer(answers)
+	logger.RunLogger.Info().Str("domain", domain).Str("owner", owner).Bool("verified", check.Verified).
+		Interface("answers", answers).Msg("DNS ownership check")
+	return check
+}
+
+// checkCNAME 查询 cname 验证记录, 任一 DNS 服务器返回预期的目标即验证通过
+func (s *OwnershipService) checkCNAME(ctx context.Context, domain, owner, token string) *model.OwnershipCheck {
+	name, target := s.CNAMERecord(domain, token)
+	answers := s.lookup(ctx, name, func(r *net.Resolver, ctx context.Context, fqdn string) ([]string, error) {
+		cname, err := r.LookupCNAME(ctx, fqdn)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strings.TrimSuffix(cname, ".")}, nil
+	})
+
+	check := &model.OwnershipCheck{Answers: answers}
+	for _, a := range answers {
+		for _, r := range a.Records {
+			if strings.EqualFold(r, target) {
+				check.Verified = true
+			}
+		}
+	}
+	check.Inconclusive = !check.Verified && !anyDefiniteAnswer(answers)
+	logger.RunLogger.Info().Str("domain", domain).Str("owner", owner).Bool("verified", check.Verified).
+		Interface("answers", answers).Msg("CNAME ownership check")
+	return check
+}
+
+// lookupFunc 向一个 DNS 服务器查询的方法, 如 (*net.Resolver).LookupTXT
+type lookupFunc func(r *net.Resolver, ctx context.Context, fqdn string) ([]string, error)
+
+// lookup 并发向各 DNS 服务器查询, 未配置时使用系统解析
+func (s *OwnershipService) lookup(ctx context.Context, name string, fn lookupFunc) []model.ResolverAnswer {
+	// 完整域名, 不追加 search 后缀
+	fqdn := name + "."
+	if len(s.resolvers) == 0 {
+		return []model.ResolverAnswer{s.query(ctx, "system", net.DefaultResolver, fqdn, fn)}
+	}
+
+	answers := make([]model.ResolverAnswer, len(s.resolvers))
+	var wg sync.WaitGroup
+	for i, addr := range s.resolvers {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			answers[i] = s.query(ctx, addr, s.newResolver(addr), fqdn, fn)
+		}(i, addr)
+	}
+	wg.Wait()
+	return answers
+}
+
+// newResolver 只向 addr 查询的解析器
+func (s *OwnershipService) newResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: s.timeout}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func (s *OwnershipService) query(ctx context.Context, label string, r *net.Resolver, fqdn string, fn lookupFunc) model.ResolverAnswer {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	answer := model.ResolverAnswer{Resolver: label}
+	records, err := fn(r, ctx, fqdn)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		answer.Error = answerNoRecord
+	case err != nil:
+		answer.Error = fmt.Sprintf("lookup failed: %v", err)
+	default:
+		answer.Records = records
+	}
+	return answer
+}
+
+// anyDefiniteAnswer 是否有 DNS 服务器给出了确定的结果: 返回了记录, 或回答记录不存在
+// 超时、SERVFAIL 等查询失败不能说明记录已删除
+func anyDefiniteAnswer(answers []model.ResolverAnswer) bool {
+	for _, a := range answers {
+		if a.Error == "" || a.Error == answerNoRecord {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFile 通过 http 及 https 获取验证文件, 任一返回 200 且内容一致即验证通过
+func (s *OwnershipService) checkFile(ctx context.Context, domain, owner, token string) *model.OwnershipCheck {
+	want := challengeValuePrefix + token
+
+	check := &model.OwnershipCheck{}
+	for _, scheme := range []string{"http", "https"} {
+		fetch, body := s.fetchFile(ctx, scheme+"://"+domain+ChallengeFilePath(token), domain)
+		if fetch.Error == "" && fetch.StatusCode == http.StatusOK && strings.TrimSpace(body) != want {
+			fetch.Error = "content mismatch"
+		}
+		if fetch.Error == "" && fetch.StatusCode != http.StatusOK {
+			fetch.Error = fmt.Sprintf("unexpected status %d", fetch.StatusCode)
+		}
+		check.Fetches = append(check.Fetches, fetch)
+		if fetch.Error == "" {
+			check.Verified = true
+			break
+		}
+	}
+	check.Inconclusive = !check.Verified && !anyDefiniteFetch(check.Fetches)
+	logger.RunLogger.Info().Str("domain", domain).Str("owner", owner).Bool("verified", check.Verified).
+		Interface("fetches", check.Fetches).Msg("File ownership check")
+	return check
+}
+
+// anyDefiniteFetch 是否有请求得到了确定的结果: 站点返回了 5xx 以外的响应且内容读取成功, 或解析到非公网地址
+// 连接失败、超时及服务端错误不能说明文件已删除
+func anyDefiniteFetch(fetches []model.FileFetch) bool {
+	for _, f := range fetches {
+		if strings.HasPrefix(f.Error, fetchAddrNotAllowed) {
+			return true
+		}
+		if f.StatusCode > 0 && f.StatusCode < http.StatusInternalServerError && !strings.HasPrefix(f.Error, fetchReadFailed) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchFile 获取验证文件, 只跟随到同一域名的重定向, 返回请求记录及响应内容
+func (s *OwnershipService) fetchFile(ctx context.Context, rawURL, domain string) (model.FileFetch, string) {
+	fetch := model.FileFetch{URL: rawURL}
+	target := rawURL
+	for {
+		resp, err := s.http.Get(ctx, target, nil)
+		if errors.Is(err, errAddrNotAllowed) {
+			fetch.Error = fmt.Sprintf("%s: %s does not resolve to a public address", fetchAddrNotAllowed, target)
+			return fetch, ""
+		}
+		if err != nil {
+			fetch.Error = err.Error()
+			return fetch, ""
+		}
+		fetch.StatusCode = resp.StatusCode
+
+		if isRedirect(resp.StatusCode) {
+			loc, err := resp.Location()
+			resp.Body.Close()
+			switch {
+			case err != nil:
+				fetch.Error = fmt.Sprintf("invalid redirect: %v", err)
+			case len(fetch.Redirects) >= maxFileRedirects:
+				fetch.Error = fmt.Sprintf("too many redirects (max %d)", maxFileRedirects)
+			case loc.Scheme != "http" && loc.Scheme != "https":
+				fetch.Error = "redirect to unsupported scheme: " + loc.Scheme
+			case !strings.EqualFold(loc.Hostname(), domain):
+				fetch.Error = "redirect to another host: " + loc.Host
+			}
+			if fetch.Error != "" {
+				return fetch, ""
+			}
+			target = loc.String()
+			fetch.Redirects = append(fetch.Redirects, target)
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+		resp.Body.Close()
+		fetch.Body = truncate(string(data), maxRecordedBody)
+		switch {
+		case err != nil:
+			fetch.Error = fmt.Sprintf("%s: %v", fetchReadFailed, err)
+		case len(data) > maxFileSize:
+			fetch.Error = fmt.Sprintf("body exceeds %d bytes", maxFileSize)
+		}
+		return fetch, string(data)
+	}
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// truncate 截断到最多 n 字节, 不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,508 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"centralHub/config"
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+func init() {
+	logger.InitLogger(false)
+}
+
+// fakeDNS 进程内的 UDP DNS 服务器, 按记录名返回 TXT 或 CNAME 记录, 未配置的记录名返回 NXDOMAIN
+type fakeDNS struct {
+	conn     net.PacketConn
+	mu       sync.Mutex
+	txt      map[string][]string
+	cname    map[string]string
+	servfail bool
+}
+
+func startFakeDNS(t *testing.T) *fakeDNS {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeDNS{conn: conn, txt: make(map[string][]string), cname: make(map[string]string)}
+	t.Cleanup(func() { conn.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeDNS) Addr() string {
+	return f.conn.LocalAddr().String()
+}
+
+func (f *fakeDNS) SetTXT(name string, values ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.txt[strings.ToLower(name)+"."] = values
+}
+
+// SetCNAME 记录名的 CNAME, 任意类型的查询都返回该记录, target 不需要以 "." 结尾
+func (f *fakeDNS) SetCNAME(name, target string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasSuffix(target, ".") {
+		target += "."
+	}
+	f.cname[strings.ToLower(name)+"."] = target
+}
+
+// SetServFail 所有查询返回 SERVFAIL
+func (f *fakeDNS) SetServFail() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.servfail = true
+}
+
+func (f *fakeDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := f.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp, err := f.answer(buf[:n]); err == nil {
+			_, _ = f.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (f *fakeDNS) answer(req []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	values, hasTXT := f.txt[strings.ToLower(q.Name.String())]
+	target, hasCNAME := f.cname[strings.ToLower(q.Name.String())]
+	servfail := f.servfail
+	f.mu.Unlock()
+	ok := hasTXT || hasCNAME
+
+	rh := dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true, RecursionDesired: h.RecursionDesired}
+	switch {
+	case servfail:
+		rh.RCode = dnsmessage.RCodeServerFailure
+		ok = false
+	case !ok:
+		rh.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, rh)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if ok && hasCNAME {
+		// 解析器查询 A/AAAA 时从应答中取 cname
+		rr := dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 60}
+		if err := b.CNAMEResource(rr, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)}); err != nil {
+			return nil, err
+		}
+	}
+	if ok && hasTXT && q.Type == dnsmessage.TypeTXT {
+		for _, v := range values {
+			rr := dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60}
+			if err := b.TXTResource(rr, dnsmessage.TXTResource{TXT: []string{v}}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Finish()
+}
+
+func newTestOwnershipService(resolver string, tokenTTL int) *OwnershipService {
+	return NewOwnershipService(nil, config.OwnershipConfig{
+		Secret:         "test-secret",
+		TokenTTL:       tokenTTL,
+		Resolvers:      []string{resolver},
+		CNAMEZone:      "verify.xldns.com",
+		ResolveTimeout: 2,
+		HTTPTimeout:    2,
+	})
+}
+
+func TestCheckDNSSignedToken(t *testing.T) {
+	dns := startFakeDNS(t)
+	s := newTestOwnershipService(dns.Addr(), 3600)
+
+	ch, err := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	dns.SetTXT(ch.RecordName, "unrelated=1", ch.Value)
+
+	check, err := s.Check(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !check.Verified {
+		t.Errorf("expected verified, answers: %+v", check.Answers)
+	}
+	if len(check.Answers) != 1 || check.Answers[0].Resolver != dns.Addr() || check.Answers[0].Error != "" {
+		t.Errorf("unexpected answers: %+v", check.Answers)
+	}
+}
+
+func TestCheckDNSWrongToken(t *testing.T) {
+	dns := startFakeDNS(t)
+	s := newTestOwnershipService(dns.Addr(), 3600)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	other, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	// 记录的是另一个挑战的 token
+	dns.SetTXT(ch.RecordName, other.Value)
+
+	check, err := s.Check(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if check.Verified {
+		t.Error("verified with another challenge's token")
+	}
+}
+
+func TestCheckDNSNoRecord(t *testing.T) {
+	dns := startFakeDNS(t)
+	s := newTestOwnershipService(dns.Addr(), 3600)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	check, err := s.Check(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if check.Verified || check.Inconclusive || len(check.Answers) != 1 || check.Answers[0].Error != "no record" {
+		t.Errorf("unexpected check: %+v", check)
+	}
+}
+
+func TestCheckDNSServFailInconclusive(t *testing.T) {
+	dns := startFakeDNS(t)
+	dns.SetServFail()
+	s := newTestOwnershipService(dns.Addr(), 3600)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	check, err := s.Check(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if check.Verified || !check.Inconclusive {
+		t.Errorf("SERVFAIL should be inconclusive: %+v", check)
+	}
+}
+
+func TestCheckDNSUnreachableInconclusive(t *testing.T) {
+	// 监听后立即关闭, 查询超时或被拒绝
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	s := newTestOwnershipService(addr, 3600)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	check, err := s.Check(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if check.Verified || !check.Inconclusive {
+		t.Errorf("unreachable resolver should be inconclusive: %+v", check)
+	}
+}
+
+func TestCheckCNAME(t *testing.T) {
+	cases := []struct {
+		name         string
+		target       func(want string) string // 返回空时不添加记录
+		servfail     bool
+		verified     bool
+		inconclusive bool
+		answerError  string
+	}{
+		{name: "matching target", target: func(want string) string { return want }, verified: true},
+		{name: "trailing dot", target: func(want string) string { return want + "." }, verified: true},
+		{name: "case difference", target: strings.ToUpper, verified: true},
+		{name: "wrong target", target: func(string) string { return "other.verify.xldns.com" }},
+		{name: "no record", target: func(string) string { return "" }, answerError: "no record"},
+		{name: "servfail", target: func(want string) string { return want }, servfail: true, inconclusive: true, answerError: "lookup failed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dns := startFakeDNS(t)
+			s := newTestOwnershipService(dns.Addr(), 3600)
+			ch, err := s.NewChallenge("example.com", "alice", model.VerifyCNAME)
+			if err != nil {
+				t.Fatalf("NewChallenge: %v", err)
+			}
+			name, want := s.CNAMERecord("example.com", ch.Token)
+			if target := tc.target(want); target != "" {
+				dns.SetCNAME(name, target)
+			}
+			if tc.servfail {
+				dns.SetServFail()
+			}
+
+			check, err := s.Check(context.Background(), ch)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if check.Verified != tc.verified || check.Inconclusive != tc.inconclusive {
+				t.Errorf("verified = %v, inconclusive = %v, want %v, %v; answers: %+v",
+					check.Verified, check.Inconclusive, tc.verified, tc.inconclusive, check.Answers)
+			}
+			if len(check.Answers) != 1 || !strings.HasPrefix(check.Answers[0].Error, tc.answerError) ||
+				(tc.answerError == "" && check.Answers[0].Error != "") {
+				t.Errorf("unexpected answers: %+v", check.Answers)
+			}
+		})
+	}
+}
+
+func TestAnyDefiniteFetch(t *testing.T) {
+	cases := []struct {
+		name    string
+		fetches []model.FileFetch
+		want    bool
+	}{
+		{"not found", []model.FileFetch{{StatusCode: 404, Error: "unexpected status 404"}}, true},
+		{"content mismatch", []model.FileFetch{{StatusCode: 200, Error: "content mismatch"}}, true},
+		{"connection refused", []model.FileFetch{{Error: "connection refused"}}, false},
+		{"server error", []model.FileFetch{{StatusCode: 503, Error: "unexpected status 503"}}, false},
+		{"read body failed", []model.FileFetch{{StatusCode: 200, Error: fetchReadFailed + ": unexpected EOF"}}, false},
+		{"https refused, http 404", []model.FileFetch{{StatusCode: 404}, {Error: "timeout"}}, true},
+	}
+	for _, tc := range cases {
+		if got := anyDefiniteFetch(tc.fetches); got != tc.want {
+			t.Errorf("%s: anyDefiniteFetch = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckDNSExpiredToken(t *testing.T) {
+	dns := startFakeDNS(t)
+	s := newTestOwnershipService(dns.Addr(), -60)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	dns.SetTXT(ch.RecordName, ch.Value)
+
+	if _, err := s.Check(context.Background(), ch); !errors.Is(err, ErrChallengeExpired) {
+		t.Errorf("err = %v, want %v", err, ErrChallengeExpired)
+	}
+}
+
+func TestCheckDNSDifferentOwner(t *testing.T) {
+	dns := startFakeDNS(t)
+	s := newTestOwnershipService(dns.Addr(), 3600)
+
+	ch, _ := s.NewChallenge("example.com", "alice", model.VerifyDNS)
+	dns.SetTXT(ch.RecordName, ch.Value)
+
+	// token 绑定所有者, 挑战被改为其他所有者时签名不匹配
+	stolen := *ch
+	stolen.Owner = "mallory"
+	if _, err := s.Check(context.Background(), &stolen); !errors.Is(err, ErrInvalidChallengeToken) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidChallengeToken)
+	}
+
+	// 其他所有者自己的挑战不能借用 alice 的记录通过
+	own, _ := s.NewChallenge("example.com", "mallory", model.VerifyDNS)
+	check, err := s.Check(context.Background(), own)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if check.Verified {
+		t.Error("verified with another owner's record")
+	}
+}
+
+func TestTruncateRuneBoundary(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel..."},
+		{"验证文件", 4, "验..."}, // 每个汉字 3 字节, 第 4 字节处于第二个汉字中间
+		{"验证文件", 6, "验证..."},
+		{"验证文件", 2, "..."},
+	}
+	for _, tc := range cases {
+		if got := truncate(tc.in, tc.n); got != tc.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tc.in, tc.n, got, tc.want)
+		}
+	}
+}
+
+// newFileTestService 允许连接本地 httptest 服务器的验证服务
+func newFileTestService() *OwnershipService {
+	s := newTestOwnershipService("127.0.0.1:53", 3600)
+	s.dialAllowed = func(netip.Addr) bool { return true }
+	return s
+}
+
+func TestFetchFileRefusesNonPublicAddress(t *testing.T) {
+	var hit bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		fmt.Fprint(w, "internal")
+	}))
+	defer srv.Close()
+
+	s := newTestOwnershipService("127.0.0.1:53", 3600)
+	fetch, body := s.fetchFile(context.Background(), srv.URL+"/secret", "127.0.0.1")
+	if hit || body != "" || fetch.Body != "" {
+		t.Errorf("request reached the loopback server: %+v", fetch)
+	}
+	if !strings.HasPrefix(fetch.Error, fetchAddrNotAllowed) {
+		t.Errorf("error = %q, want %q", fetch.Error, fetchAddrNotAllowed)
+	}
+	if !anyDefiniteFetch([]model.FileFetch{fetch}) {
+		t.Error("non-public address should be a definite result")
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":          true,
+		"2001:4860::8888":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+	}
+	for in, want := range cases {
+		if got := isPublicAddr(netip.MustParseAddr(in).Unmap()); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestFetchFileSameHostRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "proof")
+	}))
+	defer srv.Close()
+
+	fetch, body := newFileTestService().fetchFile(context.Background(), srv.URL+"/old", "127.0.0.1")
+	if fetch.Error != "" || fetch.StatusCode != http.StatusOK || body != "proof" {
+		t.Errorf("unexpected fetch: %+v, body %q", fetch, body)
+	}
+	if len(fetch.Redirects) != 1 || !strings.HasSuffix(fetch.Redirects[0], "/new") {
+		t.Errorf("redirects = %v", fetch.Redirects)
+	}
+}
+
+func TestFetchFileCrossHostRedirect(t *testing.T) {
+	var other bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "localhost") {
+			other = true
+			return
+		}
+		_, port, _ := net.SplitHostPort(r.Host)
+		http.Redirect(w, r, "http://localhost:"+port+"/file", http.StatusMovedPermanently)
+	}))
+	defer srv.Close()
+
+	fetch, _ := newFileTestService().fetchFile(context.Background(), srv.URL+"/file", "127.0.0.1")
+	if other {
+		t.Error("redirect to another host was followed")
+	}
+	if !strings.HasPrefix(fetch.Error, "redirect to another host") || fetch.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("unexpected fetch: %+v", fetch)
+	}
+}
+
+func TestFetchFileTooManyRedirects(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		http.Redirect(w, r, "/hop/"+strconv.Itoa(n+1), http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	fetch, _ := newFileTestService().fetchFile(context.Background(), srv.URL+"/hop/0", "127.0.0.1")
+	if !strings.HasPrefix(fetch.Error, "too many redirects") {
+		t.Errorf("error = %q", fetch.Error)
+	}
+	if len(fetch.Redirects) != maxFileRedirects || requests != maxFileRedirects+1 {
+		t.Errorf("followed %d redirects in %d requests, want %d", len(fetch.Redirects), requests, maxFileRedirects)
+	}
+}
+
+func TestFetchFileOversizedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 4*maxFileSize))
+	}))
+	defer srv.Close()
+
+	fetch, _ := newFileTestService().fetchFile(context.Background(), srv.URL+"/big", "127.0.0.1")
+	if fetch.Error != fmt.Sprintf("body exceeds %d bytes", maxFileSize) {
+		t.Errorf("error = %q", fetch.Error)
+	}
+	if len(fetch.Body) != maxRecordedBody+len("...") {
+		t.Errorf("recorded body has %d bytes, want %d", len(fetch.Body), maxRecordedBody+len("..."))
+	}
+}
+
+func TestFetchFileTruncatesMultibyteBody(t *testing.T) {
+	// 每个汉字 3 字节, maxRecordedBody 处于字符中间
+	content := strings.Repeat("验", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+
+	fetch, body := newFileTestService().fetchFile(context.Background(), srv.URL+"/cn", "127.0.0.1")
+	if body != content {
+		t.Errorf("body has %d bytes, want %d", len(body), len(content))
+	}
+	if !utf8.ValidString(fetch.Body) || !strings.HasSuffix(fetch.Body, "...") || len(fetch.Body) > maxRecordedBody+len("...") {
+		t.Errorf("recorded body %q is not truncated on a rune boundary", fetch.Body)
+	}
+}
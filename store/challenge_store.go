@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+	models "centralHub/model"
+)
+
+const challengeCollection = "ownership_challenges"
+
+// ChallengeStore 所有权验证挑战, _id 为 req_id
+type ChallengeStore struct {
+	DB mongo.Collection
+}
+
+func NewChallengeStore(db *mongo.Database) *ChallengeStore {
+	cs := &ChallengeStore{
+		DB: *db.Collection(challengeCollection),
+	}
+	cs.ensureIndexes()
+	return cs
+}
+
+func (cs *ChallengeStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+		// 复查按上次复查时间领取
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "rechecked_at", Value: 1}}},
+		// 只有未通过的挑战设置 purge_at
+		{Keys: bson.D{{Key: "purge_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := cs.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create ownership challenge indexes failed")
+	}
+}
+
+func (cs *ChallengeStore) Insert(ctx context.Context, ch models.OwnershipChallenge) error {
+	_, err := cs.DB.InsertOne(ctx, ch)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", ch.Domain).Msg("Insert ownership challenge failed")
+	}
+	return err
+}
+
+// FindByID 按 req_id 查找挑战, 不存在时返回 (nil, nil)
+func (cs *ChallengeStore) FindByID(ctx context.Context, id string) (*models.OwnershipChallenge, error) {
+	var ch models.OwnershipChallenge
+	err := cs.DB.FindOne(ctx, bson.M{"_id": id}).Decode(&ch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("req_id", id).Msg("Find ownership challenge failed")
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// ClaimAttempt 占用一次检查次数, 挑战已结束、已过期或次数用完时返回 (nil, nil)
+func (cs *ChallengeStore) ClaimAttempt(ctx context.Context, id string) (*models.OwnershipChallenge, error) {
+	now := time.Now()
+	filter := bson.M{
+		"_id":       id,
+		"status":    models.ChallengePending,
+		"expire_at": bson.M{"$gt": now},
+		"$expr":     bson.M{"$lt": bson.A{"$attempts", "$max_attempts"}},
+	}
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"updated_at": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var ch models.OwnershipChallenge
+	err := cs.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&ch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("req_id", id).Msg("Claim ownership challenge attempt failed")
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// Finish 记录检查结果, 只更新仍为 pending 的挑战; 通过后不再被 TTL 删除, 从通过时开始计算复查间隔
+func (cs *ChallengeStore) Finish(ctx context.Context, id, status, reason string, check *models.OwnershipCheck) error {
+	now := time.Now()
+	set := bson.M{"status": status, "updated_at": now}
+	if reason != "" {
+		set["reason"] = reason
+	}
+	if check != nil {
+		set["last_check"] = check
+	}
+	update := bson.M{"$set": set}
+	if status == models.ChallengeVerified {
+		set["verified_at"] = now
+		set["rechecked_at"] = now
+		update["$unset"] = bson.M{"purge_at": ""}
+	}
+	_, err := cs.DB.UpdateOne(ctx, bson.M{"_id": id, "status": models.ChallengePending}, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("req_id", id).Str("status", status).Msg("Update ownership challenge failed")
+	}
+	return err
+}
+
+// Supersede 同一域名及所有者的其他已通过挑战不再复查, 到 purgeAt 后删除
+func (cs *ChallengeStore) Supersede(ctx context.Context, domain, owner, keepID string, purgeAt time.Time) error {
+	filter := bson.M{
+		"domain": domain,
+		"owner":  owner,
+		"status": models.ChallengeVerified,
+		"_id":    bson.M{"$ne": keepID},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     models.ChallengeSuperseded,
+		"updated_at": time.Now(),
+		"purge_at":   purgeAt,
+	}}
+	_, err := cs.DB.UpdateMany(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", domain).Msg("Supersede ownership challenges failed")
+	}
+	return err
+}
+
+// FindVerified 域名及所有者当前有效(已通过且复查未失效)的挑战, 不存在时返回 (nil, nil)
+func (cs *ChallengeStore) FindVerified(ctx context.Context, domain, owner string) (*models.OwnershipChallenge, error) {
+	filter := bson.M{
+		"domain":  domain,
+		"owner":   owner,
+		"status":  models.ChallengeVerified,
+		"lost_at": bson.M{"$exists": false},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "verified_at", Value: -1}})
+
+	var ch models.OwnershipChallenge
+	err := cs.DB.FindOne(ctx, filter, opts).Decode(&ch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", domain).Msg("Find verified ownership challenge failed")
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// ClaimRecheck 领取一个 before 之前复查过的已通过挑战并更新复查时间, 没有时返回 (nil, nil)
+// 证明在 lostBefore 之前已失效(待停用域名)的挑战在 retryBefore 之前复查过即可领取, 以便尽快重试停用
+// 多副本下同一挑战只会被一个实例领取
+func (cs *ChallengeStore) ClaimRecheck(ctx context.Context, before, lostBefore, retryBefore time.Time) (*models.OwnershipChallenge, error) {
+	filter := bson.M{
+		"status": models.ChallengeVerified,
+		"$or": bson.A{
+			bson.M{"rechecked_at": bson.M{"$lt": before}},
+			bson.M{"lost_at": bson.M{"$lt": lostBefore}, "rechecked_at": bson.M{"$lt": retryBefore}},
+		},
+	}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"rechecked_at": now, "updated_at": now}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "rechecked_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var ch models.OwnershipChallenge
+	err := cs.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&ch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Msg("Claim ownership recheck failed")
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// SaveRecheck 记录复查结果, lostAt 为零值时清除失效标记
+func (cs *ChallengeStore) SaveRecheck(ctx context.Context, id string, check *models.OwnershipCheck, lostAt time.Time) error {
+	set := bson.M{"last_check": check, "updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if lostAt.IsZero() {
+		update["$unset"] = bson.M{"lost_at": ""}
+	} else {
+		set["lost_at"] = lostAt
+	}
+	_, err := cs.DB.UpdateOne(ctx, bson.M{"_id": id, "status": models.ChallengeVerified}, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("req_id", id).Msg("Save ownership recheck failed")
+	}
+	return err
+}
+
+// Revoke 复查失效超过宽限期, 挑战不再有效, 到 purgeAt 后删除
+func (cs *ChallengeStore) Revoke(ctx context.Context, id, reason string, check *models.OwnershipCheck, purgeAt time.Time) error {
+	update := bson.M{"$set": bson.M{
+		"status":     models.ChallengeRevoked,
+		"reason":     reason,
+		"last_check": check,
+		"updated_at": time.Now(),
+		"purge_at":   purgeAt,
+	}}
+	_, err := cs.DB.UpdateOne(ctx, bson.M{"_id": id, "status": models.ChallengeVerified}, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("req_id", id).Msg("Revoke ownership challenge failed")
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+	models "centralHub/model"
+)
+
+const cnameCollection = "cnames"
+
+// CNAMEStore cname 分配记录, _id 为 cname, domain 唯一
+type CNAMEStore struct {
+	DB mongo.Collection
+}
+
+func NewCNAMEStore(db *mongo.Database) *CNAMEStore {
+	cs := &CNAMEStore{
+		DB: *db.Collection(cnameCollection),
+	}
+	cs.ensureIndexes()
+	return cs
+}
+
+func (cs *CNAMEStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "domain", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+	}
+	if _, err := cs.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create cname indexes failed")
+	}
+}
+
+// Reserve 占用 cname, cname 或域名已被占用时返回 ErrDuplicateKey
+func (cs *CNAMEStore) Reserve(ctx context.Context, r models.CNAMEReservation) error {
+	_, err := cs.DB.InsertOne(ctx, r)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("cname", r.CNAME).Msg("Reserve cname failed")
+		return err
+	}
+	logger.RunLogger.Info().Str("cname", r.CNAME).Str("domain", r.Domain).Msg("Cname reserved")
+	return nil
+}
+
+// FindByDomain 域名已分配的 cname, 不存在时返回 (nil, nil)
+func (cs *CNAMEStore) FindByDomain(ctx context.Context, domain string) (*models.CNAMEReservation, error) {
+	var r models.CNAMEReservation
+	err := cs.DB.FindOne(ctx, bson.M{"domain": domain}).Decode(&r)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", domain).Msg("Find cname failed")
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Release 释放域名占用的 cname
+func (cs *CNAMEStore) Release(ctx context.Context, cname, domain string) error {
+	_, err := cs.DB.DeleteOne(ctx, bson.M{"_id": cname, "domain": domain})
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("cname", cname).Msg("Release cname failed")
+	}
+	return err
+}
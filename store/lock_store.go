@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+)
+
+const lockCollection = "locks"
+
+// LockStore 基于 MongoDB 的带过期时间的分布式锁, 多副本间互斥
+// 记录结构: {_id: 锁名, holder: 持有者, expire_at: 过期时间}
+type LockStore struct {
+	DB mongo.Collection
+}
+
+func NewLockStore(db *mongo.Database) *LockStore {
+	return &LockStore{
+		DB: *db.Collection(lockCollection),
+	}
+}
+
+// Acquire 获取锁, 锁空闲、已过期或已由 holder 持有时成功
+func (ls *LockStore) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.M{"_id": key, "$or": bson.A{
+		bson.M{"holder": holder},
+		bson.M{"expire_at": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{"holder": holder, "expire_at": now.Add(ttl)}}
+	_, err := ls.DB.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// 锁由他人持有且未过期, upsert 与已有 _id 冲突
+		return false, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("lock", key).Msg("Acquire lock failed")
+		return false, err
+	}
+	return true, nil
+}
+
+// Renew 续期, 锁已不属于 holder 时返回 false
+func (ls *LockStore) Renew(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	filter := bson.M{"_id": key, "holder": holder}
+	update := bson.M{"$set": bson.M{"expire_at": time.Now().Add(ttl)}}
+	res, err := ls.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("lock", key).Msg("Renew lock failed")
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// Release 释放 holder 持有的锁
+func (ls *LockStore) Release(ctx context.Context, key, holder string) error {
+	_, err := ls.DB.DeleteOne(ctx, bson.M{"_id": key, "holder": holder})
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("lock", key).Msg("Release lock failed")
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/config"
+	"centralHub/logger"
+)
+
+// NewMongoDatabase 按配置连接 MongoDB, 返回业务数据库
+func NewMongoDatabase(cfg config.MongoDBConfig) (*mongo.Database, error) {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	opts := options.Client().ApplyURI(cfg.URI).SetConnectTimeout(timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("connect mongodb failed: %w", err)
+	}
+
+	logger.RunLogger.Info().Str("database", cfg.Database).Msg("Connected to MongoDB successfully")
+	return client.Database(cfg.Database), nil
+}
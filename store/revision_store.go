@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+	models "centralHub/model"
+)
+
+const revisionCollection = "domain_revisions"
+
+// RevisionStore 域名配置版本, 只追加不修改
+type RevisionStore struct {
+	DB mongo.Collection
+}
+
+func NewRevisionStore(db *mongo.Database) *RevisionStore {
+	rs := &RevisionStore{
+		DB: *db.Collection(revisionCollection),
+	}
+	rs.ensureIndexes()
+	return rs
+}
+
+func (rs *RevisionStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "domain_id", Value: 1}, {Key: "revision", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// 一个任务只产生一个版本, 步骤重试时不重复写入
+		{Keys: bson.D{{Key: "task_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := rs.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create revision indexes failed")
+	}
+}
+
+func (rs *RevisionStore) Insert(ctx context.Context, rev models.DomainRevision) error {
+	logger.RunLogger.Info().Str("domain", rev.Domain).Int("revision", rev.Revision).Str("action", string(rev.Action)).Msg("Inserting domain revision")
+	_, err := rs.DB.InsertOne(ctx, rev)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", rev.Domain).Msg("Insert domain revision failed")
+	}
+	return err
+}
+
+// Find 查找域名的某个版本, 不存在时返回 (nil, nil)
+func (rs *RevisionStore) Find(ctx context.Context, domainID string, revision int) (*models.DomainRevision, error) {
+	return rs.findOne(ctx, bson.M{"domain_id": domainID, "revision": revision})
+}
+
+// FindByTask 查找任务产生的版本, 不存在时返回 (nil, nil)
+func (rs *RevisionStore) FindByTask(ctx context.Context, taskID string) (*models.DomainRevision, error) {
+	return rs.findOne(ctx, bson.M{"task_id": taskID})
+}
+
+func (rs *RevisionStore) findOne(ctx context.Context, filter bson.M) (*models.DomainRevision, error) {
+	var rev models.DomainRevision
+	err := rs.DB.FindOne(ctx, filter).Decode(&rev)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Interface("filter", filter).Msg("Find domain revision failed")
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// List 按版本号倒序分页查询域名的配置版本
+func (rs *RevisionStore) List(ctx context.Context, domainID string, page, size int) ([]models.DomainRevision, int64, error) {
+	query := bson.M{"domain_id": domainID}
+	total, err := rs.DB.CountDocuments(ctx, query)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain_id", domainID).Msg("Count domain revisions failed")
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "revision", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := rs.DB.Find(ctx, query, opts)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain_id", domainID).Msg("List domain revisions failed")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	revs := []models.DomainRevision{}
+	if err := cursor.All(ctx, &revs); err != nil {
+		return nil, 0, err
+	}
+	return revs, total, nil
+}
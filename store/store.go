@@ -2,7 +2,11 @@ package store
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"time"
 
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -11,57 +15,223 @@ import (
 	models "centralHub/model"
 )
 
+const domainCollection = "domains"
+
+var (
+	// ErrRevisionConflict 域名记录已被并发修改
+	ErrRevisionConflict = errors.New("domain revision conflict")
+	// ErrStatusConflict 域名状态已被并发修改
+	ErrStatusConflict = errors.New("domain status conflict")
+)
+
 type DomainStore struct {
 	DB mongo.Collection
 }
 
-func NewDomainStore() *DomainStore {
-	uri := "mongodb+srv://<username>:<password>@cluster0.example.mongodb.net/?retryWrites=true&w=majority"
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
-
-	client, err := mongo.Connect(context.TODO(), opts)
-	if err != nil {
-		logger.RunLogger.Error().Err(err).Msg("Failed to connect to MongoDB")
-		return nil
-	}
+// DomainFilter 域名列表查询条件, 零值字段不参与过滤
+type DomainFilter struct {
+	Name   string // 包含该字符串
+	Owner  string
+	Status models.DomainStatus // 为空时不包含已删除的域名
+	Type   models.DomainType
+}
 
-	logger.RunLogger.Info().Msg("Connected to MongoDB successfully")
-	return &DomainStore{
-		DB: *client.Database("your_database_name").Collection("your_collection_name"),
+func NewDomainStore(db *mongo.Database) *DomainStore {
+	ds := &DomainStore{
+		DB: *db.Collection(domainCollection),
 	}
+	ds.ensureIndexes()
+	return ds
 }
 
-func (ds *DomainStore) Close() error {
-	if err := ds.DB.Database().Client().Disconnect(context.TODO()); err != nil {
-		logger.RunLogger.Error().Err(err).Msg("Failed to disconnect MongoDB client")
-		return err
+func (ds *DomainStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "updated_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: -1}}},
+	}
+	if _, err := ds.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create domain indexes failed")
 	}
-	logger.RunLogger.Info().Msg("MongoDB client disconnected")
-	return nil
 }
 
 func (ds *DomainStore) Insert(ctx context.Context, domain models.XLDomain) error {
 	logger.RunLogger.Info().Str("domain", domain.Name).Msg("Inserting domain")
 	_, err := ds.DB.InsertOne(ctx, domain)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
 	if err != nil {
 		logger.RunLogger.Error().Err(err).Str("domain", domain.Name).Msg("Insert domain failed")
 	}
 	return err
 }
 
+// FindByID 查找域名, 不存在时返回 (nil, nil)
 func (ds *DomainStore) FindByID(ctx context.Context, id string) (*models.XLDomain, error) {
-	logger.RunLogger.Info().Str("id", id).Msg("Finding domain by ID")
+	return ds.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindByName 按域名查找, 不存在时返回 (nil, nil)
+func (ds *DomainStore) FindByName(ctx context.Context, name string) (*models.XLDomain, error) {
+	return ds.findOne(ctx, bson.M{"name": name})
+}
+
+// FindOverlapping 查找与域名重叠且属于其他用户的未删除域名, 不存在时返回 (nil, nil)
+// 重叠指: 同名域名; 泛域名 *.B 与 B 下一层的普通域名; 普通域名与其所属的泛域名
+func (ds *DomainStore) FindOverlapping(ctx context.Context, domain models.XLDomain) (*models.XLDomain, error) {
+	or := bson.A{bson.M{"name": domain.Name}}
+	if domain.IsWildcard() {
+		or = append(or, bson.M{"name": bson.M{"$regex": `^[^.*]+\.` + regexp.QuoteMeta(domain.BaseName()) + `$`}})
+	} else if parent := models.WildcardParent(domain.Name); parent != "" {
+		or = append(or, bson.M{"name": parent})
+	}
+	filter := bson.M{
+		"owner":  bson.M{"$ne": domain.Owner},
+		"status": bson.M{"$ne": models.DomainDeleted},
+		"$or":    or,
+	}
+	return ds.findOne(ctx, filter)
+}
+
+func (ds *DomainStore) findOne(ctx context.Context, filter bson.M) (*models.XLDomain, error) {
 	var domain models.XLDomain
-	err := ds.DB.FindOne(ctx, bson.M{"_id": id}).Decode(&domain)
+	err := ds.DB.FindOne(ctx, filter).Decode(&domain)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
 	if err != nil {
-		logger.RunLogger.Error().Err(err).Str("id", id).Msg("Find domain failed")
+		logger.RunLogger.Error().Err(err).Interface("filter", filter).Msg("Find domain failed")
 		return nil, err
 	}
-	logger.RunLogger.Info().Str("id", id).Msg("Domain found")
 	return &domain, nil
 }
 
+// NextConfig 按当前记录准备下一个配置版本的完整域名记录, 不写入
+// 域名不存在时为新记录, 版本号为 1; 已有记录的ID、创建时间、状态及状态变更历史保持不变
+func (ds *DomainStore) NextConfig(ctx context.Context, domain models.XLDomain) (*models.XLDomain, error) {
+	cur, err := ds.FindByName(ctx, domain.Name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	domain.UpdatedAt = now
+	if cur == nil {
+		if domain.ID == "" {
+			domain.ID = uuid.New().String()
+		}
+		domain.Revision = 1
+		domain.CreatedAt = now
+		return &domain, nil
+	}
+
+	domain.ID = cur.ID
+	domain.Revision = cur.Revision + 1
+	domain.CreatedAt = cur.CreatedAt
+	domain.Status = cur.Status
+	domain.StatusHistory = cur.StatusHistory
+	return &domain, nil
+}
+
+// ApplyConfig 写入 NextConfig 准备的记录, 应在对应版本写入之后调用
+// 以上一版本号做乐观校验, 并发写入时返回 ErrRevisionConflict; 记录已是该版本时重新写入, 用于重试
+func (ds *DomainStore) ApplyConfig(ctx context.Context, domain models.XLDomain) error {
+	revisions := bson.A{domain.Revision - 1, domain.Revision}
+	if domain.Revision == 1 {
+		// 新记录, 或尚未生成版本的记录没有 revision 字段
+		revisions = append(revisions, nil)
+	}
+	filter := bson.M{"_id": domain.ID, "revision": bson.M{"$in": revisions}}
+	_, err := ds.DB.ReplaceOne(ctx, filter, domain, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// 记录已被更新到其他版本, upsert 与已有的 _id 或域名冲突
+		return ErrRevisionConflict
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("domain", domain.Name).Msg("Save domain config failed")
+		return err
+	}
+	logger.RunLogger.Info().Str("domain", domain.Name).Int("revision", domain.Revision).Msg("Domain config saved")
+	return nil
+}
+
+// List 按更新时间倒序分页查询域名
+func (ds *DomainStore) List(ctx context.Context, filter DomainFilter, page, size int) ([]models.XLDomain, int64, error) {
+	query := bson.M{}
+	if filter.Name != "" {
+		query["name"] = bson.M{"$regex": regexp.QuoteMeta(filter.Name)}
+	}
+	if filter.Owner != "" {
+		query["owner"] = filter.Owner
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	} else {
+		query["status"] = bson.M{"$ne": models.DomainDeleted}
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+
+	total, err := ds.DB.CountDocuments(ctx, query)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Msg("Count domains failed")
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := ds.DB.Find(ctx, query, opts)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Msg("List domains failed")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	domains := []models.XLDomain{}
+	if err := cursor.All(ctx, &domains); err != nil {
+		return nil, 0, err
+	}
+	return domains, total, nil
+}
+
+// Transition 按状态变更记录修改域名状态并追加到状态历史
+// 以 rec.From 做乐观校验, 状态已被并发修改时返回 ErrStatusConflict
+func (ds *DomainStore) Transition(ctx context.Context, id string, rec models.StatusRecord) error {
+	logger.RunLogger.Info().Str("id", id).Str("from", string(rec.From)).Str("to", string(rec.To)).
+		Str("actor", rec.Actor).Str("reason", rec.Reason).Msg("Domain status transition")
+	res, err := ds.DB.UpdateOne(ctx, bson.M{"_id": id, "status": rec.From}, bson.M{
+		"$set": bson.M{"status": rec.To, "updated_at": rec.At},
+		"$push": bson.M{"status_history": bson.M{
+			"$each":  bson.A{rec},
+			"$slice": -models.MaxStatusHistory,
+		}},
+	})
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("id", id).Msg("Domain status transition failed")
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// ClearDeployment 清空已删除域名的 vendor 及 DNS 记录, 保留配置及版本历史
+func (ds *DomainStore) ClearDeployment(ctx context.Context, id string) error {
+	return ds.Update(ctx, id, bson.M{
+		"active_vendors": bson.A{},
+		"dns_record_id":  "",
+		"cname":          "",
+		"updated_at":     time.Now(),
+	})
+}
+
 func (ds *DomainStore) Update(ctx context.Context, id string, update bson.M) error {
 	logger.RunLogger.Info().Str("id", id).Interface("update", update).Msg("Updating domain")
 	_, err := ds.DB.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
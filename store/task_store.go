@@ -0,0 +1,382 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+	models "centralHub/model"
+)
+
+const taskCollection = "tasks"
+
+var (
+	// ErrLeaseLost 任务租约已被其他实例接管
+	ErrLeaseLost = errors.New("task lease lost")
+	// ErrDuplicateKey 违反唯一索引(如重复的幂等键)
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+type TaskStore struct {
+	DB mongo.Collection
+}
+
+// TaskFilter 任务列表查询条件, 零值字段不参与过滤
+type TaskFilter struct {
+	Domain string
+	Owner  string
+	State  models.TaskState
+	From   time.Time // created_at >= From
+	To     time.Time // created_at < To
+}
+
+func NewTaskStore(db *mongo.Database) *TaskStore {
+	ts := &TaskStore{
+		DB: *db.Collection(taskCollection),
+	}
+	ts.ensureIndexes()
+	return ts
+}
+
+func (ts *TaskStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "lease_expire_at", Value: 1}}},
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "wait_for", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys:    bson.D{{Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	}
+	if _, err := ts.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create task indexes failed")
+	}
+}
+
+func (ts *TaskStore) Insert(ctx context.Context, task models.Task) error {
+	logger.RunLogger.Info().Str("task_id", task.ID).Str("type", task.Type).Msg("Inserting task")
+	_, err := ts.DB.InsertOne(ctx, task)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", task.ID).Msg("Insert task failed")
+	}
+	return err
+}
+
+// FindByIdempotencyKey 按幂等键查找任务, 不存在时返回 (nil, nil)
+func (ts *TaskStore) FindByIdempotencyKey(ctx context.Context, key string) (*models.Task, error) {
+	return ts.findOne(ctx, bson.M{"idempotency_key": key}, nil)
+}
+
+// FindLatestByDomain 查找域名最近一个处于 states 中的某类型任务, 不存在时返回 (nil, nil)
+func (ts *TaskStore) FindLatestByDomain(ctx context.Context, taskType, domain string, states []models.TaskState) (*models.Task, error) {
+	filter := bson.M{"type": taskType, "domain": domain, "state": bson.M{"$in": states}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return ts.findOne(ctx, filter, opts)
+}
+
+// SetSupersededBy 记录任务被哪个新任务覆盖
+func (ts *TaskStore) SetSupersededBy(ctx context.Context, id, newID string) error {
+	_, err := ts.DB.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"superseded_by": newID}})
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Set task superseded_by failed")
+	}
+	return err
+}
+
+// FindWaitingIDs 返回排队等待 taskID 结束的任务
+func (ts *TaskStore) FindWaitingIDs(ctx context.Context, taskID string) ([]string, error) {
+	filter := bson.M{"wait_for": taskID, "state": models.TaskPending}
+	return ts.findIDs(ctx, filter)
+}
+
+// HasEarlierActive 同一域名是否有更早提交且待执行或执行中的任务, 用于按提交顺序串行执行
+// 暂停的任务不阻塞之后的任务, 恢复后按域名状态判断能否继续
+func (ts *TaskStore) HasEarlierActive(ctx context.Context, task *models.Task) (bool, error) {
+	filter := bson.M{
+		"domain": task.Domain,
+		"_id":    bson.M{"$ne": task.ID},
+		"state":  bson.M{"$in": bson.A{models.TaskPending, models.TaskRunning}},
+		"$or": bson.A{
+			bson.M{"created_at": bson.M{"$lt": task.CreatedAt}},
+			bson.M{"created_at": task.CreatedAt, "_id": bson.M{"$lt": task.ID}},
+		},
+	}
+	n, err := ts.DB.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", task.ID).Msg("Check earlier domain tasks failed")
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// FindNextPendingID 域名下最早提交的 pending 任务, 没有时返回空
+func (ts *TaskStore) FindNextPendingID(ctx context.Context, domain string) (string, error) {
+	task, err := ts.findOne(ctx, bson.M{"domain": domain, "state": models.TaskPending},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).SetProjection(bson.M{"_id": 1}))
+	if err != nil || task == nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func (ts *TaskStore) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptions) (*models.Task, error) {
+	if opts == nil {
+		opts = options.FindOne()
+	}
+	var task models.Task
+	err := ts.DB.FindOne(ctx, filter, opts).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Interface("filter", filter).Msg("Find task failed")
+		return nil, err
+	}
+	return &task, nil
+}
+
+// FindByID 查找任务, 不存在时返回 (nil, nil)
+func (ts *TaskStore) FindByID(ctx context.Context, id string) (*models.Task, error) {
+	var task models.Task
+	err := ts.DB.FindOne(ctx, bson.M{"_id": id}).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Find task failed")
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Save 写入任务记录(每个步骤完成后的检查点)
+// 以 task.LeaseOwner 作为租约校验, 租约已被其他实例接管时返回 ErrLeaseLost
+// control 和租约字段单独维护, 不随检查点覆盖
+func (ts *TaskStore) Save(ctx context.Context, task *models.Task) error {
+	task.UpdatedAt = time.Now()
+	doc, err := toBsonM(task)
+	if err != nil {
+		return err
+	}
+	delete(doc, "_id")
+	delete(doc, "control")
+	delete(doc, "lease_owner")
+	delete(doc, "lease_expire_at")
+	res, err := ts.DB.UpdateOne(ctx, bson.M{"_id": task.ID, "lease_owner": task.LeaseOwner}, bson.M{"$set": doc})
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", task.ID).Msg("Save task failed")
+		return err
+	}
+	if res.MatchedCount == 0 {
+		logger.RunLogger.Warn().Str("task_id", task.ID).Str("owner", task.LeaseOwner).Msg("Save task rejected, lease lost")
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// runnableFilter 可被领取的任务: pending, 或租约已过期的 running(持有实例已崩溃)
+func runnableFilter(now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"state": models.TaskPending},
+		bson.M{"state": models.TaskRunning, "lease_expire_at": bson.M{"$not": bson.M{"$gte": now}}},
+	}}
+}
+
+// Claim 原子地领取任务并获得租约, 任务不存在或已被其他实例持有时返回 (nil, nil)
+func (ts *TaskStore) Claim(ctx context.Context, id, owner string, ttl time.Duration) (*models.Task, error) {
+	now := time.Now()
+	filter := runnableFilter(now)
+	filter["_id"] = id
+	update := bson.M{"$set": bson.M{
+		"state":           models.TaskRunning,
+		"lease_owner":     owner,
+		"lease_expire_at": now.Add(ttl),
+		"updated_at":      now,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var task models.Task
+	err := ts.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Claim task failed")
+		return nil, err
+	}
+	return &task, nil
+}
+
+// RenewLease 续约, 返回任务当前的控制请求; 租约已不属于 owner 时返回 ErrLeaseLost
+func (ts *TaskStore) RenewLease(ctx context.Context, id, owner string, ttl time.Duration) (models.TaskControl, error) {
+	filter := bson.M{"_id": id, "lease_owner": owner, "state": models.TaskRunning}
+	update := bson.M{"$set": bson.M{"lease_expire_at": time.Now().Add(ttl)}}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"control": 1})
+
+	var doc struct {
+		Control models.TaskControl `bson:"control"`
+	}
+	err := ts.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.ControlNone, ErrLeaseLost
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Renew task lease failed")
+		return models.ControlNone, err
+	}
+	return doc.Control, nil
+}
+
+// Unclaim 放弃已领取但尚不能执行的任务, 恢复为 pending
+func (ts *TaskStore) Unclaim(ctx context.Context, id, owner string) error {
+	filter := bson.M{"_id": id, "lease_owner": owner, "state": models.TaskRunning}
+	update := bson.M{"$set": bson.M{"state": models.TaskPending, "lease_owner": ""},
+		"$unset": bson.M{"lease_expire_at": ""}}
+	_, err := ts.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Unclaim task failed")
+	}
+	return err
+}
+
+// Release 释放租约并清除已响应的控制请求
+func (ts *TaskStore) Release(ctx context.Context, id, owner string) error {
+	filter := bson.M{"_id": id, "lease_owner": owner}
+	update := bson.M{"$set": bson.M{"lease_owner": "", "control": models.ControlNone},
+		"$unset": bson.M{"lease_expire_at": ""}}
+	_, err := ts.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Msg("Release task lease failed")
+	}
+	return err
+}
+
+// Transition 任务处于 from 中的某个状态时, 原子地置为 to 并设置 control
+// 状态不匹配时返回 false
+func (ts *TaskStore) Transition(ctx context.Context, id string, from []models.TaskState, to models.TaskState, control models.TaskControl) (bool, error) {
+	filter := bson.M{"_id": id, "state": bson.M{"$in": from}}
+	update := bson.M{"$set": bson.M{"state": to, "control": control, "updated_at": time.Now()}}
+	res, err := ts.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Str("to", string(to)).Msg("Transition task failed")
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// SetControl 任务处于 states 中的某个状态时设置控制请求, 状态不匹配时返回 false
+func (ts *TaskStore) SetControl(ctx context.Context, id string, states []models.TaskState, control models.TaskControl) (bool, error) {
+	filter := bson.M{"_id": id, "state": bson.M{"$in": states}}
+	update := bson.M{"$set": bson.M{"control": control, "updated_at": time.Now()}}
+	res, err := ts.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("task_id", id).Str("control", string(control)).Msg("Set task control failed")
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// FindRunnableIDs 按创建时间顺序返回可领取的任务ID, 包括崩溃实例遗留的 running 任务
+func (ts *TaskStore) FindRunnableIDs(ctx context.Context) ([]string, error) {
+	return ts.findIDs(ctx, runnableFilter(time.Now()))
+}
+
+// findIDs 按创建时间顺序返回匹配的任务ID
+func (ts *TaskStore) findIDs(ctx context.Context, filter bson.M) ([]string, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := ts.DB.Find(ctx, filter, opts)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Interface("filter", filter).Msg("Find task ids failed")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ids []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID string `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids = append(ids, doc.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// List 按条件分页查询任务, 按创建时间倒序, page 从1开始
+func (ts *TaskStore) List(ctx context.Context, filter TaskFilter, page, size int) ([]models.Task, int64, error) {
+	query := bson.M{}
+	if filter.Domain != "" {
+		query["domain"] = filter.Domain
+	}
+	if filter.Owner != "" {
+		query["owner"] = filter.Owner
+	}
+	if filter.State != "" {
+		query["state"] = filter.State
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lt"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	total, err := ts.DB.CountDocuments(ctx, query)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Msg("Count tasks failed")
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := ts.DB.Find(ctx, query, opts)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Msg("List tasks failed")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	tasks := []models.Task{}
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// toBsonM 将结构体按 bson tag 转为 bson.M
+func toBsonM(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"centralHub/logger"
+	models "centralHub/model"
+)
+
+const (
+	vendorEventCollection = "vendor_events"
+	// 回调事件只用于推进等待中的任务, 保留一段时间便于排查
+	vendorEventRetention = 7 * 24 * time.Hour
+)
+
+// VendorEventStore vendor 回调事件, 多副本间通过它传递回调
+type VendorEventStore struct {
+	DB mongo.Collection
+}
+
+func NewVendorEventStore(db *mongo.Database) *VendorEventStore {
+	es := &VendorEventStore{
+		DB: *db.Collection(vendorEventCollection),
+	}
+	es.ensureIndexes()
+	return es
+}
+
+func (es *VendorEventStore) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "vendor", Value: 1}, {Key: "domain", Value: 1}, {Key: "received_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "received_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(vendorEventRetention.Seconds())),
+		},
+	}
+	if _, err := es.DB.Indexes().CreateMany(ctx, indexes); err != nil {
+		logger.RunLogger.Warn().Err(err).Msg("Create vendor event indexes failed")
+	}
+}
+
+func (es *VendorEventStore) Insert(ctx context.Context, event models.VendorEvent) error {
+	logger.RunLogger.Info().Str("vendor", event.Vendor).Str("domain", event.Domain).
+		Str("status", string(event.Status)).Msg("Inserting vendor event")
+	_, err := es.DB.InsertOne(ctx, event)
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("vendor", event.Vendor).Msg("Insert vendor event failed")
+	}
+	return err
+}
+
+// FindLatest 返回 since 之后收到的最新事件, 不存在时返回 (nil, nil)
+func (es *VendorEventStore) FindLatest(ctx context.Context, vendor, domain string, since time.Time) (*models.VendorEvent, error) {
+	filter := bson.M{"vendor": vendor, "domain": domain, "received_at": bson.M{"$gte": since}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "received_at", Value: -1}})
+	var event models.VendorEvent
+	err := es.DB.FindOne(ctx, filter, opts).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.RunLogger.Error().Err(err).Str("vendor", vendor).Str("domain", domain).Msg("Find vendor event failed")
+		return nil, err
+	}
+	return &event, nil
+}
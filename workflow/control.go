@@ -0,0 +1,142 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrInvalidTaskState = errors.New("operation not allowed in current task state")
+
+	// 作为任务 context 的取消原因, 区分暂停和取消
+	errTaskPaused    = errors.New("task paused")
+	errTaskCancelled = errors.New("task cancelled")
+	errLeaseLost     = errors.New("task lease lost")
+)
+
+// trackRunning 记录本进程正在执行任务的取消函数
+func (wf *Workflow) trackRunning(taskID string, cancel context.CancelCauseFunc) {
+	wf.runningMu.Lock()
+	defer wf.runningMu.Unlock()
+	wf.running[taskID] = cancel
+}
+
+func (wf *Workflow) untrackRunning(taskID string) {
+	wf.runningMu.Lock()
+	defer wf.runningMu.Unlock()
+	delete(wf.running, taskID)
+}
+
+// interruptLocal 若任务在本进程执行, 以 cause 取消其 context
+// 在其他实例执行的任务由其心跳读取 control 后中断
+func (wf *Workflow) interruptLocal(taskID string, cause error) {
+	wf.runningMu.Lock()
+	defer wf.runningMu.Unlock()
+	if cancel, ok := wf.running[taskID]; ok {
+		cancel(cause)
+	}
+}
+
+/*
+CancelTask 取消任务
+
+	pending/paused 且没有已完成步骤: 直接置为 cancelled
+	pending/paused 且有已完成步骤: 重新排队, 由 worker 补偿已完成的步骤后置为 cancelled
+	running: 记录取消请求并取消执行中的 context, 当前步骤中断后补偿
+*/
+func (wf *Workflow) CancelTask(ctx context.Context, taskID string) error {
+	task, err := wf.tasks.FindByID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return ErrTaskNotFound
+	}
+
+	var ok bool
+	switch task.State {
+	case model.TaskPending, model.TaskPaused:
+		if !hasSucceededStep(task) {
+			ok, err = wf.tasks.Transition(ctx, taskID, []model.TaskState{task.State}, model.TaskCancelled, model.ControlNone)
+			if ok {
+				wf.wakeWaiters(ctx, taskID)
+			}
+			break
+		}
+		ok, err = wf.tasks.Transition(ctx, taskID, []model.TaskState{task.State}, model.TaskPending, model.ControlCancel)
+		if ok {
+			wf.enqueue(taskID)
+		}
+	case model.TaskRunning:
+		ok, err = wf.tasks.SetControl(ctx, taskID, []model.TaskState{model.TaskRunning}, model.ControlCancel)
+		if ok {
+			wf.interruptLocal(taskID, errTaskCancelled)
+		}
+	}
+	return controlResult(taskID, "cancel", ok, err)
+}
+
+// PauseTask 暂停任务, 执行中的任务在当前步骤中断后置为 paused, 已完成的步骤保留
+func (wf *Workflow) PauseTask(ctx context.Context, taskID string) error {
+	task, err := wf.tasks.FindByID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return ErrTaskNotFound
+	}
+
+	var ok bool
+	switch task.State {
+	case model.TaskPending:
+		ok, err = wf.tasks.Transition(ctx, taskID, []model.TaskState{model.TaskPending}, model.TaskPaused, model.ControlNone)
+	case model.TaskRunning:
+		ok, err = wf.tasks.SetControl(ctx, taskID, []model.TaskState{model.TaskRunning}, model.ControlPause)
+		if ok {
+			wf.interruptLocal(taskID, errTaskPaused)
+		}
+	}
+	return controlResult(taskID, "pause", ok, err)
+}
+
+// ResumeTask 恢复暂停的任务, 从最后一个完成的步骤之后继续执行
+func (wf *Workflow) ResumeTask(ctx context.Context, taskID string) error {
+	task, err := wf.tasks.FindByID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return ErrTaskNotFound
+	}
+
+	ok, err := wf.tasks.Transition(ctx, taskID, []model.TaskState{model.TaskPaused}, model.TaskPending, model.ControlNone)
+	if ok {
+		wf.enqueue(taskID)
+	}
+	return controlResult(taskID, "resume", ok, err)
+}
+
+// controlResult 状态已变化导致条件更新未命中时返回 ErrInvalidTaskState
+func controlResult(taskID, op string, ok bool, err error) error {
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTaskState
+	}
+	logger.RunLogger.Info().Str("task_id", taskID).Str("op", op).Msg("Task control accepted")
+	return nil
+}
+
+func hasSucceededStep(task *model.Task) bool {
+	for _, step := range task.Steps {
+		if step.Status == model.StepSucceeded {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"centralHub/model"
+	"centralHub/store"
+)
+
+var (
+	// ErrDomainNotFound 域名不存在
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrInvalidDomainState 域名当前状态不允许该操作
+	ErrInvalidDomainState = errors.New("operation not allowed in current domain state")
+)
+
+// GetDomain 查询域名, 不存在时返回 (nil, nil)
+func (wf *Workflow) GetDomain(ctx context.Context, id string) (*model.XLDomain, error) {
+	return wf.domains.FindByID(ctx, id)
+}
+
+// ListDomains 分页查询域名
+func (wf *Workflow) ListDomains(ctx context.Context, filter store.DomainFilter, page, size int) ([]model.XLDomain, int64, error) {
+	return wf.domains.List(ctx, filter, page, size)
+}
+
+// loadDomain 按ID查询域名, 不存在或已删除时返回 ErrDomainNotFound
+func (wf *Workflow) loadDomain(ctx context.Context, id string) (*model.XLDomain, error) {
+	domain, err := wf.domains.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil || domain.Status == model.DomainDeleted {
+		return nil, ErrDomainNotFound
+	}
+	return domain, nil
+}
+
+// checkDomainOp 域名当前状态是否允许该操作, domain 为 nil 表示记录尚不存在
+func checkDomainOp(domain *model.XLDomain, op model.DomainOp) error {
+	var status model.DomainStatus
+	if domain != nil {
+		status = domain.Status
+	}
+	if !status.Allows(op) {
+		return fmt.Errorf("%w: can not %s domain in status %q", ErrInvalidDomainState, op, status)
+	}
+	return nil
+}
+
+/*
+transitionDomain 变更域名状态, 所有状态变更都经过这里
+
+	只允许 model.DomainStatus.CanTransition 中的变更, 否则返回 ErrInvalidDomainState
+	已处于目标状态时直接返回, 步骤重试时不重复记录
+	rec 中的操作人、原因、任务ID随变更写入状态历史
+*/
+func (wf *Workflow) transitionDomain(ctx context.Context, id string, to model.DomainStatus, rec model.StatusRecord) error {
+	domain, err := wf.domains.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if domain == nil {
+		return ErrDomainNotFound
+	}
+	if domain.Status == to {
+		return nil
+	}
+	if !domain.Status.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidDomainState, domain.Status, to)
+	}
+	rec.From, rec.To, rec.At = domain.Status, to, time.Now()
+	return wf.domains.Transition(ctx, id, rec)
+}
+
+// transitionTaskDomain 由任务步骤变更域名状态, 操作人为任务的提交者
+func (wf *Workflow) transitionTaskDomain(ctx context.Context, sc *StepContext, id string, to model.DomainStatus, reason string) error {
+	rec := model.StatusRecord{Reason: reason, TaskID: sc.TaskID()}
+	sc.Update(func(task *model.Task) {
+		rec.Actor = task.Actor
+	})
+	return wf.transitionDomain(ctx, id, to, rec)
+}
+
+// submitDomainTask 提交域名操作任务, 同一域名的操作按提交顺序排队执行
+func (wf *Workflow) submitDomainTask(ctx context.Context, taskType string, domain model.XLDomain, opts SubmitOptions) (*SubmitResult, error) {
+	opts.Policy = model.DuplicateQueue
+	// 状态历史不随任务保存
+	domain.StatusHistory = nil
+	if opts.Actor == "" {
+		opts.Actor = domain.Owner
+	}
+	return wf.SubmitTask(ctx, taskType, domain, opts)
+}
+
+// PatchDomain 按 JSON Merge Patch 修改域名配置, 提交 update_domain 任务推送到全部 vendor
+// 提交时应用到当前配置做校验, patch 随任务保存, 执行时重新应用到届时的配置, 排队的多个修改不会互相覆盖
+func (wf *Workflow) PatchDomain(ctx context.Context, id string, patch []byte, opts SubmitOptions) (*SubmitResult, error) {
+	domain, err := wf.loadDomain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomainOp(domain, model.DomainOpUpdate); err != nil {
+		return nil, err
+	}
+	updated, err := domain.ApplyPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	opts.Patch = patch
+	return wf.submitDomainTask(ctx, model.TaskTypeUpdateDomain, updated, opts)
+}
+
+// RemoveDomain 提交 delete_domain 任务: 删除 DNS 记录, 删除各 vendor 上的域名, 释放 cname
+func (wf *Workflow) RemoveDomain(ctx context.Context, id string, opts SubmitOptions) (*SubmitResult, error) {
+	domain, err := wf.loadDomain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomainOp(domain, model.DomainOpDelete); err != nil {
+		return nil, err
+	}
+	return wf.submitDomainTask(ctx, model.TaskTypeDeleteDomain, *domain, opts)
+}
+
+// SetDomainEnabled 提交启用/停用任务, 在域名已部署的全部 vendor 上启用或停用
+// 启用因所有权失效停用(suspended)的域名前须重新验证所有权, 未验证时返回 ErrOwnershipNotVerified
+func (wf *Workflow) SetDomainEnabled(ctx context.Context, id string, enabled bool, opts SubmitOptions) (*SubmitResult, error) {
+	domain, err := wf.loadDomain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	taskType, op := model.TaskTypeDisableDomain, model.DomainOpDisable
+	if enabled {
+		taskType, op = model.TaskTypeEnableDomain, model.DomainOpEnable
+	}
+	if err := checkDomainOp(domain, op); err != nil {
+		return nil, err
+	}
+	if enabled && domain.Status == model.DomainSuspended {
+		if err := wf.checkOwnership(ctx, *domain); err != nil {
+			return nil, err
+		}
+	}
+	return wf.submitDomainTask(ctx, taskType, *domain, opts)
+}
+
+// suspendActor 所有权复查停用域名时记录的操作人
+const suspendActor = "ownership-recheck"
+
+// 仍可能执行的 suspend_domain 任务状态, 已有时不重复提交
+var activeSuspendStates = []model.TaskState{model.TaskPending, model.TaskRunning, model.TaskPaused}
+
+/*
+SuspendDomains 所有权验证失效后, 提交 suspend_domain 任务停用 owner 名下的 domain 及 *.domain
+
+	进行中(configuring, deploying)的域名排在当前任务之后停用, 其他不在服务中的域名跳过
+	已有未结束的 suspend_domain 任务时不重复提交, 之前的任务失败后重新提交
+	返回是否已全部停用(suspended)及相关的任务ID, 未全部停用时由调用方稍后再次调用
+*/
+func (wf *Workflow) SuspendDomains(ctx context.Context, domain, owner string) (bool, []string, error) {
+	done := true
+	var taskIDs []string
+	for _, name := range []string{domain, "*." + domain} {
+		d, err := wf.domains.FindByName(ctx, name)
+		if err != nil {
+			return false, taskIDs, err
+		}
+		if d == nil || d.Owner != owner {
+			continue
+		}
+		if d.Status == model.DomainSuspended {
+			last, err := wf.tasks.FindLatestByDomain(ctx, model.TaskTypeSuspendDomain, d.Name, []model.TaskState{model.TaskSucceeded})
+			if err != nil {
+				return false, taskIDs, err
+			}
+			if last != nil {
+				taskIDs = append(taskIDs, last.ID)
+			}
+			continue
+		}
+		if !d.Status.Allows(model.DomainOpSuspend) && d.Status != model.DomainConfiguring && d.Status != model.DomainDeploying {
+			continue
+		}
+
+		done = false
+		active, err := wf.tasks.FindLatestByDomain(ctx, model.TaskTypeSuspendDomain, d.Name, activeSuspendStates)
+		if err != nil {
+			return false, taskIDs, err
+		}
+		if active != nil {
+			taskIDs = append(taskIDs, active.ID)
+			continue
+		}
+		result, err := wf.submitDomainTask(ctx, model.TaskTypeSuspendDomain, *d, SubmitOptions{Actor: suspendActor})
+		if err != nil {
+			return false, taskIDs, err
+		}
+		taskIDs = append(taskIDs, result.Task.ID)
+	}
+	return done, taskIDs, nil
+}
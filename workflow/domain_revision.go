@@ -0,0 +1,123 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"centralHub/model"
+	"centralHub/store"
+)
+
+// ErrRevisionNotFound 配置版本不存在
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// saveDomainRevision 生成配置版本并写入域名记录, 版本号写入步骤产出 revision
+// 先写入版本, 由 (domain_id, revision) 唯一索引保证并发写入时只有一个成功, 再按版本号条件更新域名记录
+// 每个任务只生成一个版本, 步骤重试时沿用已生成的版本, 并补写尚未更新的域名记录
+func (wf *Workflow) saveDomainRevision(ctx context.Context, sc *StepContext, domain model.XLDomain, action model.RevisionAction) error {
+	existing, err := wf.revisions.FindByTask(ctx, sc.TaskID())
+	if err != nil {
+		return err
+	}
+	next, err := wf.domains.NextConfig(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		switch {
+		case next.Revision > existing.Revision:
+			// 域名记录已更新
+			sc.Set("revision", strconv.Itoa(existing.Revision))
+			return nil
+		case next.Revision < existing.Revision:
+			return fmt.Errorf("%w: domain %s is behind revision %d", store.ErrRevisionConflict, domain.Name, existing.Revision)
+		}
+	} else {
+		rev := model.DomainRevision{
+			ID:        uuid.New().String(),
+			DomainID:  next.ID,
+			Domain:    next.Name,
+			Revision:  next.Revision,
+			Action:    action,
+			TaskID:    sc.TaskID(),
+			Snapshot:  next.ConfigSnapshot(),
+			CreatedAt: time.Now(),
+		}
+		sc.Update(func(task *model.Task) {
+			rev.Author = task.Actor
+			rev.RequestID = task.RequestID
+			rev.SourceRevision = task.SourceRevision
+		})
+		if err := wf.revisions.Insert(ctx, rev); err != nil {
+			if errors.Is(err, store.ErrDuplicateKey) {
+				// 该版本号已被并发的任务占用
+				return fmt.Errorf("%w: revision %d of %s already exists", store.ErrRevisionConflict, rev.Revision, rev.Domain)
+			}
+			return err
+		}
+	}
+
+	if err := wf.domains.ApplyConfig(ctx, *next); err != nil {
+		return err
+	}
+	sc.Set("revision", strconv.Itoa(next.Revision))
+	return nil
+}
+
+// ListRevisions 分页查询域名的配置版本, 按版本号倒序
+func (wf *Workflow) ListRevisions(ctx context.Context, domainID string, page, size int) ([]model.DomainRevision, int64, error) {
+	return wf.revisions.List(ctx, domainID, page, size)
+}
+
+// getRevision 查询域名的某个版本, 不存在时返回 ErrRevisionNotFound
+func (wf *Workflow) getRevision(ctx context.Context, domainID string, revision int) (*model.DomainRevision, error) {
+	rev, err := wf.revisions.Find(ctx, domainID, revision)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil {
+		return nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, revision)
+	}
+	return rev, nil
+}
+
+// DiffRevisions 比较域名的两个配置版本, 返回 from 到 to 的变化
+func (wf *Workflow) DiffRevisions(ctx context.Context, domainID string, from, to int) ([]model.ConfigChange, error) {
+	a, err := wf.getRevision(ctx, domainID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := wf.getRevision(ctx, domainID, to)
+	if err != nil {
+		return nil, err
+	}
+	return model.DiffConfig(a.Snapshot, b.Snapshot)
+}
+
+/*
+RollbackDomain 回滚到历史版本
+以该版本的配置提交 update_domain 任务, 推送到域名已部署的全部 vendor, 成功后生成新版本(action=rollback)
+同一域名的配置任务按提交顺序排队执行
+*/
+func (wf *Workflow) RollbackDomain(ctx context.Context, domainID string, revision int, opts SubmitOptions) (*SubmitResult, error) {
+	domain, err := wf.loadDomain(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomainOp(domain, model.DomainOpUpdate); err != nil {
+		return nil, err
+	}
+	rev, err := wf.getRevision(ctx, domainID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.SourceRevision = revision
+	return wf.submitDomainTask(ctx, model.TaskTypeUpdateDomain, domain.WithConfig(rev.Snapshot), opts)
+}
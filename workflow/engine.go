@@ -0,0 +1,307 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+)
+
+// TaskHandler 执行某一类型任务, 返回错误时任务置为失败
+type TaskHandler func(ctx context.Context, task *model.Task) error
+
+// RegisterHandler 注册任务类型对应的执行函数
+func (wf *Workflow) RegisterHandler(taskType string, handler TaskHandler) {
+	wf.handlers[taskType] = handler
+}
+
+// PushTask 持久化任务并投递到执行队列, 立即返回任务ID
+func (wf *Workflow) PushTask(ctx context.Context, taskType string, input model.XLDomain) (string, error) {
+	task, err := wf.newTask(taskType, input)
+	if err != nil {
+		return "", err
+	}
+	if err := wf.insertTask(ctx, task); err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+func (wf *Workflow) newTask(taskType string, input model.XLDomain) (*model.Task, error) {
+	if _, ok := wf.handlers[taskType]; !ok {
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+
+	now := time.Now()
+	return &model.Task{
+		ID:        uuid.New().String(),
+		Type:      taskType,
+		Domain:    input.Name,
+		Owner:     input.Owner,
+		State:     model.TaskPending,
+		Input:     input,
+		Steps:     []model.TaskStep{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// insertTask 持久化并投递
+func (wf *Workflow) insertTask(ctx context.Context, task *model.Task) error {
+	if err := wf.tasks.Insert(ctx, *task); err != nil {
+		return fmt.Errorf("save task failed: %w", err)
+	}
+	wf.enqueue(task.ID)
+	return nil
+}
+
+// Start 启动任务 worker
+// 启动时及之后定期扫描 pending 任务和租约过期的 running 任务(崩溃实例遗留),
+// 通过租约领取, 多副本下同一任务只会被一个实例执行, 从最后的检查点继续
+func (wf *Workflow) Start(ctx context.Context) {
+	for i := 0; i < wf.cfg.Workers; i++ {
+		go wf.worker(ctx)
+	}
+	go wf.scanLoop(ctx)
+
+	logger.RunLogger.Info().Int("workers", wf.cfg.Workers).Str("instance", wf.instanceID).Msg("Workflow engine started")
+}
+
+// enqueue 非阻塞投递, 队列满时由定时扫描补投
+func (wf *Workflow) enqueue(taskID string) {
+	select {
+	case wf.queue <- taskID:
+	default:
+		logger.RunLogger.Warn().Str("task_id", taskID).Msg("Task queue full, waiting for rescan")
+	}
+}
+
+// scanLoop 定期从存储中扫描可执行任务投递到队列
+func (wf *Workflow) scanLoop(ctx context.Context) {
+	wf.scanRunnable(ctx)
+
+	ticker := time.NewTicker(time.Duration(wf.cfg.ScanInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			wf.scanRunnable(ctx)
+		}
+	}
+}
+
+func (wf *Workflow) scanRunnable(ctx context.Context) {
+	ids, err := wf.tasks.FindRunnableIDs(ctx)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		wf.enqueue(id)
+	}
+}
+
+func (wf *Workflow) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-wf.queue:
+			wf.runTask(ctx, id)
+		}
+	}
+}
+
+// runTask 领取并执行任务, 重复投递或已被其他实例持有的任务在领取时被过滤
+func (wf *Workflow) runTask(ctx context.Context, taskID string) {
+	// 排队的任务等待前一个任务结束, 前一个任务结束时会重新投递; 领取前检查, 阻塞的任务不反复领取、释放
+	queued, err := wf.tasks.FindByID(ctx, taskID)
+	if err != nil || queued == nil {
+		return
+	}
+	if blocked, err := wf.isBlocked(ctx, queued); err != nil || blocked {
+		return
+	}
+
+	task, err := wf.tasks.Claim(ctx, taskID, wf.instanceID, wf.leaseTTL())
+	if err != nil || task == nil {
+		return
+	}
+
+	rlog := logger.WithReqID(task.ID)
+
+	// 同一域名的任务串行执行, 锁存于 store 中跨副本互斥
+	if !wf.lockDomain(ctx, task) {
+		_ = wf.tasks.Unclaim(ctx, task.ID, wf.instanceID)
+		return
+	}
+	defer wf.unlockDomain(ctx, task)
+	rlog.Info().Str("type", task.Type).Str("domain", task.Domain).Msg("Task started")
+
+	// 任务 context 的取消会传递到各步骤及 vendor 调用
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	wf.trackRunning(task.ID, cancel)
+	defer wf.untrackRunning(task.ID)
+	if task.Control == model.ControlCancel {
+		cancel(errTaskCancelled)
+	}
+
+	// 心跳独立于任务 context, 补偿期间继续续约
+	hbCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go wf.heartbeat(hbCtx, task, cancel)
+
+	handler, ok := wf.handlers[task.Type]
+	if !ok {
+		err = fmt.Errorf("unknown task type: %s", task.Type)
+	} else {
+		err = handler(taskCtx, task)
+	}
+
+	switch {
+	case errors.Is(err, errLeaseLost) || errors.Is(err, store.ErrLeaseLost):
+		// 已被其他实例接管, 不再写入
+		rlog.Warn().Msg("Task lease lost, execution abandoned")
+		return
+	case err == nil:
+		task.State = model.TaskSucceeded
+		task.Error = ""
+		rlog.Info().Msg("Task succeeded")
+	case errors.Is(err, errTaskPaused):
+		task.State = model.TaskPaused
+		rlog.Info().Msg("Task paused")
+	case errors.Is(err, errTaskCancelled):
+		task.State = model.TaskCancelled
+		task.Error = err.Error()
+		rlog.Info().Msg("Task cancelled")
+	default:
+		task.State = model.TaskFailed
+		task.Error = err.Error()
+		rlog.Error().Err(err).Msg("Task failed")
+	}
+	stopHeartbeat()
+	if err := wf.tasks.Save(ctx, task); err != nil {
+		return
+	}
+	_ = wf.tasks.Release(ctx, task.ID, wf.instanceID)
+
+	if task.State.IsTerminal() || task.State == model.TaskPaused {
+		wf.wakeWaiters(ctx, task.ID)
+	}
+}
+
+// isBlocked 任务需等待: 显式排在另一个未结束的任务之后, 或同一域名有更早提交的未结束任务
+// 暂停的任务不阻塞其他任务, 否则一个暂停的任务会让该域名之后的任务无限期等待
+func (wf *Workflow) isBlocked(ctx context.Context, task *model.Task) (bool, error) {
+	if task.WaitFor != "" {
+		prev, err := wf.tasks.FindByID(ctx, task.WaitFor)
+		if err != nil {
+			return false, err
+		}
+		if prev != nil && !prev.State.IsTerminal() && prev.State != model.TaskPaused {
+			return true, nil
+		}
+	}
+	if task.Domain == "" {
+		return false, nil
+	}
+	return wf.tasks.HasEarlierActive(ctx, task)
+}
+
+func domainLockKey(domain string) string {
+	return "domain:" + domain
+}
+
+// lockHolder 域名锁持有者, 锁与任务租约同时过期, 接管任务的实例在过期后获取
+func (wf *Workflow) lockHolder(task *model.Task) string {
+	return wf.instanceID + "/" + task.ID
+}
+
+// lockDomain 获取域名锁
+func (wf *Workflow) lockDomain(ctx context.Context, task *model.Task) bool {
+	if task.Domain == "" {
+		return true
+	}
+	ok, err := wf.locks.Acquire(ctx, domainLockKey(task.Domain), wf.lockHolder(task), wf.leaseTTL())
+	return err == nil && ok
+}
+
+// unlockDomain 释放域名锁并投递该域名下一个排队的任务
+func (wf *Workflow) unlockDomain(ctx context.Context, task *model.Task) {
+	if task.Domain == "" {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	_ = wf.locks.Release(ctx, domainLockKey(task.Domain), wf.lockHolder(task))
+	if next, err := wf.tasks.FindNextPendingID(ctx, task.Domain); err == nil && next != "" {
+		wf.enqueue(next)
+	}
+}
+
+// wakeWaiters 投递排队等待 taskID 的任务
+func (wf *Workflow) wakeWaiters(ctx context.Context, taskID string) {
+	ids, err := wf.tasks.FindWaitingIDs(ctx, taskID)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		wf.enqueue(id)
+	}
+}
+
+// heartbeat 定期续约任务租约和域名锁, 并响应其他实例写入的控制请求, 租约丢失时中断任务
+func (wf *Workflow) heartbeat(ctx context.Context, task *model.Task, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(wf.leaseTTL() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if task.Domain != "" {
+			if ok, err := wf.locks.Renew(ctx, domainLockKey(task.Domain), wf.lockHolder(task), wf.leaseTTL()); err == nil && !ok {
+				cancel(errLeaseLost)
+				return
+			}
+		}
+		control, err := wf.tasks.RenewLease(ctx, task.ID, wf.instanceID, wf.leaseTTL())
+		switch {
+		case errors.Is(err, store.ErrLeaseLost):
+			cancel(errLeaseLost)
+			return
+		case err != nil:
+			// 临时错误, 租约未过期前继续重试
+			continue
+		}
+		switch control {
+		case model.ControlPause:
+			cancel(errTaskPaused)
+		case model.ControlCancel:
+			cancel(errTaskCancelled)
+		}
+	}
+}
+
+func (wf *Workflow) leaseTTL() time.Duration {
+	return time.Duration(wf.cfg.LeaseTTL) * time.Second
+}
+
+// GetTask 查询任务详情, 不存在时返回 (nil, nil)
+func (wf *Workflow) GetTask(ctx context.Context, taskID string) (*model.Task, error) {
+	return wf.tasks.FindByID(ctx, taskID)
+}
+
+// ListTasks 分页查询任务历史
+func (wf *Workflow) ListTasks(ctx context.Context, filter store.TaskFilter, page, size int) ([]model.Task, int64, error) {
+	return wf.tasks.List(ctx, filter, page, size)
+}
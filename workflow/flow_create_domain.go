@@ -1,80 +1,457 @@
 package workflow
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"centralHub/client"
+	"centralHub/logger"
 	"centralHub/model"
 )
 
-// 域名cname采用拼接方式： xxx+随机+.www
-// dnspod subdomain长度限制50, www长度4, xxx长度自定义, 随机码长度[1,13]
-// 随机码长度[1,13], .www 长度4
-const dnspodMaxSubdomainLen = 50
-
-func (wf *Workflow) makeCname(c *gin.Context, obj model.XLDomain) string {
-	domainName := "" // placeholder, from obj
-	uuid := uuid.New()
-	var cnamePrefix string
-	var start, end = 0, len(domainName)
-	var subDomainLen = dnspodMaxSubdomainLen - 4 - len(uuid.String())
-	if len(domainName) > subDomainLen {
-		cnamePrefix = uuid.String()
-	} else {
-		if strings.HasPrefix(domainName, ".") { // 泛域名
-			start = 1
+// createVendorDomain 在各vendor上创建域名, 返回每个vendor的结果(与 vendors 顺序一致)
+// prev 中已成功的vendor不再调用; 成功数未达到 quorum 时返回 *QuorumError
+func (wf *Workflow) createVendorDomain(ctx context.Context, obj model.XLDomain, vendors []string, prev []model.VendorResult, idempotencyKey string) ([]model.VendorResult, error) {
+	if len(vendors) == 0 {
+		return nil, ErrNoVendorAvailable
+	}
+	prevByVendor := make(map[string]model.VendorResult, len(prev))
+	for _, r := range prev {
+		prevByVendor[r.Vendor] = r
+	}
+
+	// 调用vendor的接口创建域名
+	results := make([]model.VendorResult, len(vendors))
+	errs := make([]error, len(vendors))
+	var wg sync.WaitGroup
+	for i, v := range vendors {
+		if r, ok := prevByVendor[v]; ok && r.Success {
+			results[i] = r
+			continue
 		}
-		cnamePrefix = domainName[start:end]
-		// dnspod最低用户权限不支持subdomain的域名层次超过3层，这里普通域名设置成1层，泛域名设置为2层
-		cnamePrefix = strings.Replace(cnamePrefix, ".", "-", -1)
-		cnamePrefix = cnamePrefix + "-" + uuid.String()
+		wg.Add(1)
+		go func(i int, vendor string) {
+			defer wg.Done()
+
+			res := model.VendorResult{Vendor: vendor, Attempts: prevByVendor[vendor].Attempts + 1}
+			start := time.Now()
+			var d *model.VendorDomain
+			if v := wf.vendors.Get(vendor); v == nil {
+				errs[i] = fmt.Errorf("vendor %s: client not registered", vendor)
+			} else {
+				d, res.Created, errs[i] = createOnVendor(ctx, v.Client, model.VendorDomainRequest{
+					Domain:         obj,
+					IdempotencyKey: idempotencyKey,
+				})
+				v.ReportResult(errs[i])
+			}
+			res.LatencyMs = time.Since(start).Milliseconds()
+			res.UpdatedAt = time.Now()
+			if errs[i] != nil {
+				res.ErrorKind = string(client.KindOf(errs[i]))
+				res.Error = errs[i].Error()
+			} else {
+				res.Success = true
+				if d != nil {
+					res.CNAME, res.Status = d.CNAME, d.Status
+				}
+			}
+			results[i] = res
+		}(i, v)
 	}
-	if strings.HasPrefix(domainName, ".") { // 泛域名
-		cnamePrefix = cnamePrefix + ".www"
+	wg.Wait()
+
+	// 三方对接, 是异步任务, 回调或者轮询
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+		}
+	}
+	required := requiredSuccesses(wf.vendorPolicy.cfg.Quorum, len(vendors))
+	if succeeded < required {
+		return results, &QuorumError{Succeeded: succeeded, Required: required, Total: len(vendors), Err: errors.Join(errs...)}
 	}
+	return results, nil
+}
 
-	cnameSuffix := ".xldns.com" // placeholder, to define
+// requiredSuccesses 按 quorum 计算需要成功的vendor数, 不超过 total
+func requiredSuccesses(quorum string, total int) int {
+	switch quorum {
+	case "", "all":
+		return total
+	case "any":
+		return 1
+	}
+	n, err := strconv.Atoi(quorum)
+	if err != nil || n <= 0 {
+		return total
+	}
+	return min(n, total)
+}
+
+// QuorumError 成功的vendor数未达到 quorum
+type QuorumError struct {
+	Succeeded int
+	Required  int
+	Total     int
+	Err       error // 失败vendor的错误
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("vendor quorum not met: %d/%d succeeded, %d required: %v", e.Succeeded, e.Total, e.Required, e.Err)
+}
+
+func (e *QuorumError) Unwrap() error {
+	return e.Err
+}
 
-	return cnamePrefix + cnameSuffix
+// Retryable 任一失败的vendor可重试时整体可重试
+func (e *QuorumError) Retryable() bool {
+	if e.Err == nil {
+		return false
+	}
+	joined, ok := e.Err.(interface{ Unwrap() []error })
+	if !ok {
+		return client.IsRetryable(e.Err)
+	}
+	for _, err := range joined.Unwrap() {
+		if client.IsRetryable(err) {
+			return true
+		}
+	}
+	return false
 }
 
-func (wf *Workflow) createVendorDomain(c *gin.Context, obj model.XLDomain) string {
-	// 1, 确定要使用的vendor
-	vendors := []string{"mock-vendor"}
+// retryableVendorError 创建vendor域名失败后是否重试
+func retryableVendorError(err error) bool {
+	if errors.Is(err, ErrNoVendorAvailable) {
+		return false
+	}
+	var qe *QuorumError
+	if errors.As(err, &qe) {
+		return qe.Retryable()
+	}
+	return client.IsRetryable(err)
+}
 
-	// 2, 调用vendor的接口创建域名
+// firstCNAME 按vendor顺序返回第一个成功的vendor分配的cname
+func firstCNAME(results []model.VendorResult) string {
+	for _, r := range results {
+		if r.Success && r.CNAME != "" {
+			return r.CNAME
+		}
+	}
+	return ""
+}
+
+// createOnVendor 创建vendor域名, 已存在时沿用已有域名, 返回是否由本次调用创建
+// 已存在的域名可能在本任务之前就已存在, 也可能是上次超时的调用已创建, 无法区分时按已存在处理, 补偿时不删除
+func createOnVendor(ctx context.Context, vendorClt VendorClient, req model.VendorDomainRequest) (*model.VendorDomain, bool, error) {
+	d, err := vendorClt.CreateDomain(ctx, req)
+	if client.IsKind(err, client.ErrKindConflict) {
+		d, err = vendorClt.GetDomain(ctx, req.Domain.Name)
+		return d, false, err
+	}
+	return d, err == nil, err
+}
+
+// createdVendors 本任务创建了域名的vendor
+func createdVendors(results []model.VendorResult) []string {
+	var vendors []string
+	for _, r := range results {
+		if r.Created {
+			vendors = append(vendors, r.Vendor)
+		}
+	}
+	return vendors
+}
+
+// deleteVendorDomain 删除各vendor上的域名, 用于补偿; vendor上不存在时视为成功
+func (wf *Workflow) deleteVendorDomain(ctx context.Context, obj model.XLDomain, vendors []string) error {
+	errs := make([]error, len(vendors))
 	var wg sync.WaitGroup
-	for _, v := range vendors {
+	for i, v := range vendors {
 		wg.Add(1)
-		go func(vendor string) {
+		go func(i int, vendor string) {
 			defer wg.Done()
 
 			vendorClt := wf.getVendorClient(vendor)
-			_ = vendorClt.CreateDomain(c, obj)
-		}(v)
+			if vendorClt == nil {
+				return
+			}
+			err := vendorClt.DeleteDomain(ctx, obj.Name)
+			if err != nil && !client.IsKind(err, client.ErrKindNotFound) {
+				errs[i] = err
+			}
+		}(i, v)
 	}
-	// 3, 返回vendor的域名
 	wg.Wait()
+	return errors.Join(errs...)
+}
 
-	// placeholder
-	// 三方对接, 是异步任务, 回调或者轮询
-	return ""
+// CreateDomain 流水线的步骤名
+const (
+	StepRegisterDomain     = "register_domain"
+	StepMakeCname          = "make_cname"
+	StepCreateVendorDomain = "create_vendor_domain"
+	StepWaitVendorOnline   = "wait_vendor_online"
+	StepCreateDNSRecord    = "create_dns_record"
+	StepSaveDomain         = "save_domain"
+)
+
+// registerDomain 新建域名记录或沿用已删除、部署失败的记录, 状态置为 configuring, 返回域名ID
+func (wf *Workflow) registerDomain(ctx context.Context, sc *StepContext) (string, error) {
+	cur, err := wf.domains.FindByName(ctx, sc.Input().Name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkDomainOp(cur, model.DomainOpCreate); err != nil {
+		return "", err
+	}
+	// 提交后其他用户可能已创建了重叠的域名, 或所有权验证已失效
+	if err := wf.checkOverlap(ctx, sc.Input()); err != nil {
+		return "", err
+	}
+	if err := wf.checkOwnership(ctx, sc.Input()); err != nil {
+		return "", err
+	}
+	if cur != nil {
+		return cur.ID, wf.transitionTaskDomain(ctx, sc, cur.ID, model.DomainConfiguring, "create domain")
+	}
+
+	now := time.Now()
+	domain := sc.Input()
+	domain.ID = uuid.New().String()
+	domain.Status = model.DomainConfiguring
+	domain.StatusHistory = []model.StatusRecord{{
+		To: model.DomainConfiguring, Reason: "create domain", TaskID: sc.TaskID(), At: now,
+	}}
+	sc.Update(func(task *model.Task) {
+		domain.StatusHistory[0].Actor = task.Actor
+	})
+	domain.CreatedAt, domain.UpdatedAt = now, now
+	if err := wf.domains.Insert(ctx, domain); err != nil {
+		return "", err
+	}
+	return domain.ID, nil
 }
 
 /*
-CreateDomain 创建域名的工作流
-1, make Cname
-2, create vendor domain
-3,
+CreateDomain 创建域名的工作流, 由任务 worker 异步执行
+1, register domain, 写入域名记录, 状态 configuring
+2, make Cname, 分配并占用 cname
+3, create vendor domain
+4, wait vendor domain online, 轮询或回调, 状态 deploying
+5, create dns record, cname -> vendor cname
+6, save domain, 写入域名配置并生成首个配置版本, 状态 online
+后续 ICP 检查、所有权检查、验证 以步骤形式注册
+任一步骤失败时逆序补偿: 删除DNS记录, 删除本任务创建的vendor域名, 释放cname, 状态 deploy_failed
+DNS 服务商尚未对接, create dns record 记录为跳过, 不创建也不删除解析记录
 */
-func (wf *Workflow) CreateDomain(c *gin.Context, obj model.XLDomain) string {
-
-	cname := wf.makeCname(c, obj)
-
-	_ = wf.createVendorDomain(c, obj)
+func (wf *Workflow) CreateDomain() *Pipeline {
+	return NewPipeline(model.TaskTypeCreateDomain).
+		AddStep(Step{
+			Name:    StepRegisterDomain,
+			Timeout: 10 * time.Second,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second,
+				RetryIf: func(err error) bool {
+					return !errors.Is(err, ErrInvalidDomainState) && !errors.Is(err, ErrDomainOverlap) &&
+						!errors.Is(err, ErrOwnershipNotVerified)
+				},
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				if id := sc.Get("domain_id"); id != "" {
+					return wf.transitionTaskDomain(ctx, sc, id, model.DomainConfiguring, "create domain")
+				}
+				id, err := wf.registerDomain(ctx, sc)
+				if err != nil {
+					return err
+				}
+				sc.Set("domain_id", id)
+				return nil
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				id := sc.Get("domain_id")
+				if id == "" {
+					return nil
+				}
+				return wf.transitionTaskDomain(ctx, sc, id, model.DomainDeployFailed, "create domain task failed, rolled back")
+			},
+		}).
+		AddStep(Step{
+			Name:    StepMakeCname,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 同一域名重试时沿用已分配的 cname
+				r, err := wf.cnames.Allocate(ctx, sc.Input(), sc.TaskID())
+				if err != nil {
+					return err
+				}
+				sc.Set("cname", r.CNAME)
+				sc.Set("cname_zone", r.Zone)
+				sc.Set("cname_subdomain", r.SubDomain)
+				return nil
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				// 只释放本任务分配的 cname, 之前任务分配的仍在使用
+				r, err := wf.cnames.Get(ctx, sc.Input().Name)
+				if err != nil {
+					return err
+				}
+				if r != nil && r.TaskID == sc.TaskID() {
+					if err := wf.cnames.Release(ctx, r); err != nil {
+						return err
+					}
+				}
+				sc.Set("cname", "")
+				sc.Set("cname_zone", "")
+				sc.Set("cname_subdomain", "")
+				return nil
+			},
+		}).
+		AddStep(Step{
+			Name:    StepCreateVendorDomain,
+			Timeout: 2 * time.Minute,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second,
+				RetryIf: retryableVendorError,
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 重试时沿用首次选择的vendor, 补偿时按记录删除
+				vendors := splitList(sc.Get("vendors"))
+				if len(vendors) == 0 {
+					decisions := wf.vendorPolicy.Select(sc.Input())
+					vendors = SelectedVendors(decisions)
+					sc.Update(func(task *model.Task) {
+						task.VendorSelection = decisions
+					})
+					sc.Set("vendors", strings.Join(vendors, ","))
+				}
+				var prev []model.VendorResult
+				sc.Update(func(task *model.Task) {
+					prev = task.VendorResults
+				})
+				results, err := wf.createVendorDomain(ctx, sc.Input(), vendors, prev, sc.IdempotencyKey())
+				sc.Update(func(task *model.Task) {
+					task.VendorResults = results
+				})
+				if err != nil {
+					return err
+				}
+				sc.Set("vendor_cname", firstCNAME(results))
+				return nil
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				// 只删除本任务创建的vendor域名, 沿用的已有域名保留
+				var vendors []string
+				sc.Update(func(task *model.Task) {
+					vendors = createdVendors(task.VendorResults)
+				})
+				return wf.deleteVendorDomain(ctx, sc.Input(), vendors)
+			},
+		}).
+		AddStep(Step{
+			Name: StepWaitVendorOnline,
+			// 超时即失败, 不重试
+			Timeout: time.Duration(wf.cfg.VendorWaitTimeout) * time.Second,
+			Run: func(ctx context.Context, sc *StepContext) error {
+				if err := wf.transitionTaskDomain(ctx, sc, sc.Get("domain_id"), model.DomainDeploying, "vendor domains created"); err != nil {
+					return err
+				}
+				var prev []model.VendorResult
+				sc.Update(func(task *model.Task) {
+					prev = task.VendorResults
+				})
+				results, err := wf.waitVendorsOnline(ctx, sc.Input().Name, prev)
+				sc.Update(func(task *model.Task) {
+					task.VendorResults = results
+				})
+				if err != nil {
+					return err
+				}
+				cname := firstCNAME(results)
+				if cname == "" {
+					return fmt.Errorf("no vendor returned a cname for %s", sc.Input().Name)
+				}
+				sc.Set("vendor_cname", cname)
+				return nil
+			},
+		}).
+		AddStep(Step{
+			Name:    StepCreateDNSRecord,
+			Timeout: 30 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 由 create/wait 步骤记录, 为空时不创建指向空目标的记录
+				target := sc.Get("vendor_cname")
+				if target == "" {
+					return fmt.Errorf("vendor cname of %s is empty", sc.Input().Name)
+				}
+				recordID, err := wf.dnsClient.CreateRecord(ctx, sc.Get("cname_zone"), sc.Get("cname_subdomain"), "CNAME", target)
+				if errors.Is(err, client.ErrDNSNotImplemented) {
+					// 未创建记录, 不记录 dns_record_id, 补偿时无需删除
+					logger.RunLogger.Warn().Str("task_id", sc.TaskID()).Str("domain", sc.Input().Name).
+						Msg("DNS provider not integrated, dns record skipped")
+					sc.Set("dns_record", "skipped: "+err.Error())
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				sc.Set("dns_record_id", recordID)
+				return nil
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				recordID := sc.Get("dns_record_id")
+				if recordID == "" {
+					return nil
+				}
+				if err := wf.dnsClient.DeleteRecord(ctx, sc.Get("cname_zone"), recordID); err != nil {
+					return err
+				}
+				sc.Set("dns_record_id", "")
+				return nil
+			},
+		}).
+		AddStep(Step{
+			Name:    StepSaveDomain,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				domain := sc.Input()
+				domain.ID = sc.Get("domain_id")
+				domain.CNAME = sc.Get("cname")
+				domain.DNSRecordID = sc.Get("dns_record_id")
+				sc.Update(func(task *model.Task) {
+					for _, r := range task.VendorResults {
+						if r.Success {
+							domain.ActiveVendors = append(domain.ActiveVendors, r.Vendor)
+						}
+					}
+				})
+				if err := wf.saveDomainRevision(ctx, sc, domain, model.RevisionCreate); err != nil {
+					return err
+				}
+				return wf.transitionTaskDomain(ctx, sc, domain.ID, model.DomainOnline,
+					"deployed to vendors: "+strings.Join(domain.ActiveVendors, ","))
+			},
+		})
+}
 
-	return cname
+// splitList 逗号分隔的列表, 空串返回 nil
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
 }
@@ -0,0 +1,136 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"centralHub/client"
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+// DeleteDomain 流水线的步骤名
+const (
+	StepMarkDeleting       = "mark_deleting"
+	StepDeleteDNSRecord    = "delete_dns_record"
+	StepDeleteVendorDomain = "delete_vendor_domain"
+	StepReleaseCname       = "release_cname"
+	StepMarkDeleted        = "mark_deleted"
+)
+
+// taskDomain 任务对应的域名记录
+func (wf *Workflow) taskDomain(ctx context.Context, sc *StepContext) (*model.XLDomain, error) {
+	domain, err := wf.domains.FindByID(ctx, sc.Input().ID)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, ErrDomainNotFound
+	}
+	return domain, nil
+}
+
+/*
+DeleteDomain 删除域名的工作流
+1, mark deleting, 状态 deleting
+2, delete dns record, 先停止解析
+3, delete vendor domain, 删除已部署的全部 vendor 上的域名
+4, release cname
+5, mark deleted, 保留域名记录及配置版本, 状态 deleted
+删除无法补偿, 各步骤幂等, 失败时状态置为 delete_failed, 可重新提交删除
+*/
+func (wf *Workflow) DeleteDomain() *Pipeline {
+	return NewPipeline(model.TaskTypeDeleteDomain).
+		AddStep(Step{
+			Name:    StepMarkDeleting,
+			Timeout: 10 * time.Second,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second,
+				RetryIf: func(err error) bool { return !errors.Is(err, ErrInvalidDomainState) },
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 排队期间状态可能已变化, 首次执行时再次检查
+				if sc.Get("prev_status") == "" {
+					domain, err := wf.taskDomain(ctx, sc)
+					if err != nil {
+						return err
+					}
+					if err := checkDomainOp(domain, model.DomainOpDelete); err != nil {
+						return err
+					}
+					sc.Set("prev_status", string(domain.Status))
+				}
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, model.DomainDeleting, "delete domain")
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				if sc.Get("prev_status") == "" {
+					return nil
+				}
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, model.DomainDeleteFailed, "delete domain task failed")
+			},
+		}).
+		AddStep(Step{
+			Name:    StepDeleteDNSRecord,
+			Timeout: 30 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				domain, err := wf.taskDomain(ctx, sc)
+				if err != nil || domain.DNSRecordID == "" {
+					return err
+				}
+				zone := wf.cnames.SuffixFor(domain.Owner)
+				if r, err := wf.cnames.Get(ctx, domain.Name); err != nil {
+					return err
+				} else if r != nil {
+					zone = r.Zone
+				}
+				err = wf.dnsClient.DeleteRecord(ctx, zone, domain.DNSRecordID)
+				if errors.Is(err, client.ErrDNSNotImplemented) {
+					// 之前的占位实现记录了不存在的记录ID
+					logger.RunLogger.Warn().Str("task_id", sc.TaskID()).Str("domain", domain.Name).
+						Msg("DNS provider not integrated, dns record deletion skipped")
+					return nil
+				}
+				return err
+			},
+		}).
+		AddStep(Step{
+			Name:    StepDeleteVendorDomain,
+			Timeout: 2 * time.Minute,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second,
+				RetryIf: retryableVendorError,
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				domain, err := wf.taskDomain(ctx, sc)
+				if err != nil {
+					return err
+				}
+				return wf.deleteVendorDomain(ctx, *domain, domain.ActiveVendors)
+			},
+		}).
+		AddStep(Step{
+			Name:    StepReleaseCname,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				r, err := wf.cnames.Get(ctx, sc.Input().Name)
+				if err != nil || r == nil {
+					return err
+				}
+				return wf.cnames.Release(ctx, r)
+			},
+		}).
+		AddStep(Step{
+			Name:    StepMarkDeleted,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				if err := wf.domains.ClearDeployment(ctx, sc.Input().ID); err != nil {
+					return err
+				}
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, model.DomainDeleted, "delete domain")
+			},
+		})
+}
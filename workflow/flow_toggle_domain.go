@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"centralHub/model"
+)
+
+// ToggleDomain 流水线的步骤名
+const (
+	StepEnableVendorDomain  = "enable_vendor_domain"
+	StepDisableVendorDomain = "disable_vendor_domain"
+	StepUpdateDomainStatus  = "update_domain_status"
+)
+
+// toggleVendorDomain 在各 vendor 上启用或停用域名
+func (wf *Workflow) toggleVendorDomain(ctx context.Context, domain string, vendors []string, enable bool) error {
+	var errs []error
+	for _, name := range vendors {
+		v := wf.vendors.Get(name)
+		if v == nil {
+			errs = append(errs, fmt.Errorf("vendor %s: client not registered", name))
+			continue
+		}
+		var err error
+		if enable {
+			err = v.Client.EnableDomain(ctx, domain)
+		} else {
+			err = v.Client.DisableDomain(ctx, domain)
+		}
+		v.ReportResult(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vendor %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+ToggleDomain 启用/停用域名的工作流
+1, 在已部署的全部 vendor 上启用或停用, 失败时恢复已操作的 vendor; 启用 suspended 的域名前再次检查所有权
+2, 更新域名状态 online / disabled
+*/
+func (wf *Workflow) ToggleDomain(enable bool) *Pipeline {
+	taskType, stepName, op, status := model.TaskTypeDisableDomain, StepDisableVendorDomain, model.DomainOpDisable, model.DomainDisabled
+	reason := "disabled on vendors: "
+	if enable {
+		taskType, stepName, op, status = model.TaskTypeEnableDomain, StepEnableVendorDomain, model.DomainOpEnable, model.DomainOnline
+		reason = "enabled on vendors: "
+	}
+	return NewPipeline(taskType).
+		AddStep(Step{
+			Name:    stepName,
+			Timeout: time.Minute,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second,
+				RetryIf: func(err error) bool {
+					return !errors.Is(err, ErrOwnershipNotVerified) && retryableVendorError(err)
+				},
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 排队期间状态可能已变化, 执行前再次检查; 检查通过后记录 vendor, 补偿时按记录恢复
+				domain, err := wf.taskDomain(ctx, sc)
+				if err != nil {
+					return err
+				}
+				if err := checkDomainOp(domain, op); err != nil {
+					return err
+				}
+				if enable && domain.Status == model.DomainSuspended {
+					if err := wf.checkOwnership(ctx, *domain); err != nil {
+						return err
+					}
+				}
+				sc.Set("vendors", strings.Join(domain.ActiveVendors, ","))
+				return wf.toggleVendorDomain(ctx, domain.Name, domain.ActiveVendors, enable)
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				return wf.toggleVendorDomain(ctx, sc.Input().Name, splitList(sc.Get("vendors")), !enable)
+			},
+		}).
+		AddStep(Step{
+			Name:    StepUpdateDomainStatus,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, status, reason+sc.Get("vendors"))
+			},
+		})
+}
+
+/*
+SuspendDomain 所有权验证失效后停用域名的工作流
+1, 在已部署的全部 vendor 上停用, 除状态不允许外的错误都重试
+2, 更新域名状态 suspended
+没有补偿: 失败时已停用的 vendor 保持停用, 由所有权复查重新提交, 直到全部停用
+*/
+func (wf *Workflow) SuspendDomain() *Pipeline {
+	return NewPipeline(model.TaskTypeSuspendDomain).
+		AddStep(Step{
+			Name:    StepDisableVendorDomain,
+			Timeout: time.Minute,
+			Retry: RetryPolicy{
+				MaxAttempts: 10, Backoff: 5 * time.Second, MaxBackoff: 5 * time.Minute,
+				RetryIf: func(err error) bool { return !errors.Is(err, ErrInvalidDomainState) },
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				domain, err := wf.taskDomain(ctx, sc)
+				if err != nil {
+					return err
+				}
+				if err := checkDomainOp(domain, model.DomainOpSuspend); err != nil {
+					return err
+				}
+				sc.Set("vendors", strings.Join(domain.ActiveVendors, ","))
+				return wf.toggleVendorDomain(ctx, domain.Name, domain.ActiveVendors, false)
+			},
+		}).
+		AddStep(Step{
+			Name:    StepUpdateDomainStatus,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 30 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, model.DomainSuspended,
+					"ownership verification lost, disabled on vendors: "+sc.Get("vendors"))
+			},
+		})
+}
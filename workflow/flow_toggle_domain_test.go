@@ -0,0 +1,30 @@
+package workflow
+
+import (
+	"fmt"
+	"testing"
+
+	"centralHub/client"
+)
+
+// 停用中途失败时已停用的 vendor 保持停用, 不能由补偿重新启用
+func TestSuspendDomainNeverCompensates(t *testing.T) {
+	p := (&Workflow{}).SuspendDomain()
+	if len(p.steps) == 0 {
+		t.Fatal("suspend pipeline has no steps")
+	}
+	for _, s := range p.steps {
+		if s.Compensate != nil {
+			t.Errorf("step %s has a compensation", s.Name)
+		}
+	}
+
+	retryIf := p.steps[0].Retry.RetryIf
+	vendorErr := &client.VendorError{Vendor: "v1", Op: "DisableDomain", Kind: client.ErrKindInvalid}
+	if !retryIf(vendorErr) {
+		t.Error("vendor errors should be retried until the domain is suspended")
+	}
+	if retryIf(fmt.Errorf("%w: deleted -> suspended", ErrInvalidDomainState)) {
+		t.Error("invalid domain state should not be retried")
+	}
+}
@@ -0,0 +1,170 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+// UpdateDomain 流水线的步骤名
+const (
+	StepMarkConfiguring    = "mark_configuring"
+	StepUpdateVendorConfig = "update_vendor_config"
+	StepSaveRevision       = "save_revision"
+)
+
+// updateVendorConfig 将配置推送到各 vendor, 任一 vendor 失败即返回错误
+func (wf *Workflow) updateVendorConfig(ctx context.Context, obj model.XLDomain, vendors []string, idempotencyKey string) error {
+	var errs []error
+	for _, name := range vendors {
+		v := wf.vendors.Get(name)
+		if v == nil {
+			errs = append(errs, fmt.Errorf("vendor %s: client not registered", name))
+			continue
+		}
+		err := v.Client.UpdateDomainConfig(ctx, model.VendorDomainRequest{Domain: obj, IdempotencyKey: idempotencyKey})
+		v.ReportResult(err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vendor %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyTaskPatch 将任务的 merge patch 应用到当前域名记录并替换任务输入, 没有 patch 时不变
+func applyTaskPatch(sc *StepContext, cur model.XLDomain) error {
+	var patch string
+	sc.Update(func(task *model.Task) {
+		patch = task.Patch
+	})
+	if patch == "" {
+		return nil
+	}
+	updated, err := cur.ApplyPatch([]byte(patch))
+	if err != nil {
+		return err
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	// 状态历史不随任务保存
+	updated.StatusHistory = nil
+	sc.Update(func(task *model.Task) {
+		task.Input = updated
+	})
+	return nil
+}
+
+/*
+UpdateDomain 更新域名配置的工作流, 回滚同样使用该流程
+1, mark configuring, 记录更新前的状态(online 或 disabled), 状态 configuring
+2, update vendor config, PATCH 提交的任务先将 patch 应用到当前配置, 推送到域名已部署的全部 vendor
+3, save revision, 写入域名记录并生成新版本, 恢复更新前的状态
+推送失败时按更新前的版本恢复各 vendor 的配置及状态
+*/
+func (wf *Workflow) UpdateDomain() *Pipeline {
+	return NewPipeline(model.TaskTypeUpdateDomain).
+		AddStep(Step{
+			Name:    StepMarkConfiguring,
+			Timeout: 10 * time.Second,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second,
+				RetryIf: func(err error) bool { return !errors.Is(err, ErrInvalidDomainState) },
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 排队期间状态可能已变化, 首次执行时再次检查
+				if sc.Get("prev_status") == "" {
+					domain, err := wf.taskDomain(ctx, sc)
+					if err != nil {
+						return err
+					}
+					if err := checkDomainOp(domain, model.DomainOpUpdate); err != nil {
+						return err
+					}
+					sc.Set("prev_status", string(domain.Status))
+				}
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, model.DomainConfiguring, "update config")
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				prev := model.DomainStatus(sc.Get("prev_status"))
+				if prev == "" {
+					return nil
+				}
+				return wf.transitionTaskDomain(ctx, sc, sc.Input().ID, prev, "update config failed, previous config restored")
+			},
+		}).
+		AddStep(Step{
+			Name:    StepUpdateVendorConfig,
+			Timeout: 2 * time.Minute,
+			Retry: RetryPolicy{
+				MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second,
+				RetryIf: func(err error) bool {
+					var verr *model.ValidationError
+					return !errors.As(err, &verr) && retryableVendorError(err)
+				},
+			},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				// 首次执行时记录更新前的版本及 vendor, 重试和补偿时沿用
+				// 在此之前排队的修改已写入域名记录, patch 应用到当前配置后作为任务输入, 之后的步骤及重试使用该输入
+				if sc.Get("prev_revision") == "" {
+					cur, err := wf.domains.FindByName(ctx, sc.Input().Name)
+					if err != nil {
+						return err
+					}
+					if cur == nil {
+						return ErrDomainNotFound
+					}
+					if err := applyTaskPatch(sc, *cur); err != nil {
+						return err
+					}
+					sc.Set("domain_id", cur.ID)
+					sc.Set("prev_revision", strconv.Itoa(cur.Revision))
+					sc.Set("vendors", strings.Join(cur.ActiveVendors, ","))
+				}
+				return wf.updateVendorConfig(ctx, sc.Input(), splitList(sc.Get("vendors")), sc.IdempotencyKey())
+			},
+			Compensate: func(ctx context.Context, sc *StepContext) error {
+				prev, _ := strconv.Atoi(sc.Get("prev_revision"))
+				if prev == 0 {
+					logger.RunLogger.Warn().Str("task_id", sc.TaskID()).Msg("No previous revision to restore")
+					return nil
+				}
+				rev, err := wf.getRevision(ctx, sc.Get("domain_id"), prev)
+				if err != nil {
+					return err
+				}
+				return wf.updateVendorConfig(ctx, sc.Input().WithConfig(rev.Snapshot), splitList(sc.Get("vendors")), "")
+			},
+		}).
+		AddStep(Step{
+			Name:    StepSaveRevision,
+			Timeout: 10 * time.Second,
+			Retry:   RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			Run: func(ctx context.Context, sc *StepContext) error {
+				cur, err := wf.domains.FindByName(ctx, sc.Input().Name)
+				if err != nil {
+					return err
+				}
+				if cur == nil {
+					return ErrDomainNotFound
+				}
+				action := model.RevisionUpdate
+				sc.Update(func(task *model.Task) {
+					if task.SourceRevision > 0 {
+						action = model.RevisionRollback
+					}
+				})
+				if err := wf.saveDomainRevision(ctx, sc, cur.WithConfig(sc.Input()), action); err != nil {
+					return err
+				}
+				return wf.transitionTaskDomain(ctx, sc, cur.ID, model.DomainStatus(sc.Get("prev_status")),
+					"config revision "+sc.Get("revision")+" applied")
+			},
+		})
+}
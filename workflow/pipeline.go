@@ -0,0 +1,469 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+/*
+Pipeline 声明式的任务流水线
+	由命名步骤组成, 步骤通过 DependsOn 构成 DAG, 依赖全部成功的步骤并发执行
+	未声明 DependsOn 的步骤默认依赖前一个注册的步骤, 即按注册顺序串行
+	每个步骤有独立的超时、重试策略和幂等键
+	步骤可声明补偿动作(saga), 流水线失败时按完成的逆序执行补偿
+*/
+
+// RetryPolicy 步骤重试策略, 零值表示只执行一次
+type RetryPolicy struct {
+	MaxAttempts int                  // 最大执行次数(含首次)
+	Backoff     time.Duration        // 首次重试等待时间, 之后指数增长
+	MaxBackoff  time.Duration        // 最大等待时间
+	RetryIf     func(err error) bool // 判断错误是否可重试, nil 表示都可重试
+}
+
+// StepFunc 步骤执行函数
+type StepFunc func(ctx context.Context, sc *StepContext) error
+
+// Step 流水线中的一个命名步骤
+type Step struct {
+	Name      string
+	DependsOn []string
+	Timeout   time.Duration // 单次执行超时, 0 表示不限制
+	Retry     RetryPolicy
+	// IdempotencyKey 步骤幂等键, 已成功且键相同的步骤在任务重跑时跳过
+	// nil 时默认为 任务ID/步骤名
+	IdempotencyKey func(task *model.Task) string
+	Run            StepFunc
+	// Compensate 补偿动作, 失败的步骤也会执行(可能已部分生效), 须保证幂等
+	Compensate      StepFunc
+	CompensateRetry RetryPolicy // 零值时使用 defaultCompensateRetry
+}
+
+var defaultCompensateRetry = RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+
+// compensateTimeout 单次补偿的超时
+const compensateTimeout = time.Minute
+
+// Pipeline 有序/DAG 步骤列表
+type Pipeline struct {
+	Name  string
+	steps []Step
+}
+
+func NewPipeline(name string) *Pipeline {
+	return &Pipeline{Name: name}
+}
+
+// AddStep 注册步骤
+func (p *Pipeline) AddStep(step Step) *Pipeline {
+	if step.DependsOn == nil && len(p.steps) > 0 {
+		step.DependsOn = []string{p.steps[len(p.steps)-1].Name}
+	}
+	p.steps = append(p.steps, step)
+	return p
+}
+
+// Steps 返回已注册的步骤
+func (p *Pipeline) Steps() []Step {
+	return p.steps
+}
+
+// validate 检查步骤名唯一、依赖存在且无环
+func (p *Pipeline) validate() error {
+	index := make(map[string]int, len(p.steps))
+	for i, s := range p.steps {
+		if s.Name == "" || s.Run == nil {
+			return fmt.Errorf("pipeline %s: step %d missing name or run func", p.Name, i)
+		}
+		if _, ok := index[s.Name]; ok {
+			return fmt.Errorf("pipeline %s: duplicate step %s", p.Name, s.Name)
+		}
+		index[s.Name] = i
+	}
+
+	// 0 未访问, 1 访问中, 2 已完成
+	state := make(map[string]int, len(p.steps))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("pipeline %s: dependency cycle at step %s", p.Name, name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range p.steps[index[name]].DependsOn {
+			if _, ok := index[dep]; !ok {
+				return fmt.Errorf("pipeline %s: step %s depends on unknown step %s", p.Name, name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for _, s := range p.steps {
+		if err := visit(s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterPipeline 注册任务类型对应的流水线
+func (wf *Workflow) RegisterPipeline(taskType string, p *Pipeline) {
+	if err := p.validate(); err != nil {
+		panic(err)
+	}
+	wf.RegisterHandler(taskType, func(ctx context.Context, task *model.Task) error {
+		return wf.runPipeline(ctx, p, task)
+	})
+}
+
+// StepContext 步骤执行上下文, 并发步骤通过它安全地读写任务数据
+type StepContext struct {
+	task *model.Task
+	mu   *sync.Mutex
+	key  string
+}
+
+// TaskID 当前任务ID
+func (sc *StepContext) TaskID() string {
+	return sc.task.ID
+}
+
+// Input 任务输入
+func (sc *StepContext) Input() model.XLDomain {
+	return sc.task.Input
+}
+
+// IdempotencyKey 当前步骤幂等键, 可透传给外部接口防止重复创建
+func (sc *StepContext) IdempotencyKey() string {
+	return sc.key
+}
+
+// Get 读取步骤产出
+func (sc *StepContext) Get(key string) string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.task.Output[key]
+}
+
+// Set 写入步骤产出
+func (sc *StepContext) Set(key, value string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.task.Output == nil {
+		sc.task.Output = make(map[string]string)
+	}
+	sc.task.Output[key] = value
+}
+
+// Update 在锁内修改任务记录
+func (sc *StepContext) Update(fn func(task *model.Task)) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	fn(sc.task)
+}
+
+// pipelineRun 一次流水线执行的状态
+type pipelineRun struct {
+	wf   *Workflow
+	p    *Pipeline
+	task *model.Task
+	mu   sync.Mutex
+	rlog zerolog.Logger
+	// 已执行(成功或失败)的步骤, 按完成顺序, 用于逆序补偿
+	executed []Step
+}
+
+// runPipeline 按依赖分批执行步骤, 同一批次内并发
+func (wf *Workflow) runPipeline(ctx context.Context, p *Pipeline, task *model.Task) error {
+	run := &pipelineRun{wf: wf, p: p, task: task, rlog: logger.WithReqID(task.ID)}
+	run.initSteps()
+
+	done := make(map[string]bool, len(p.steps))
+	for len(done) < len(p.steps) {
+		if ctx.Err() != nil {
+			return run.interrupted(ctx)
+		}
+
+		var ready []Step
+		for _, s := range p.steps {
+			if !done[s.Name] && run.depsDone(s, done) {
+				ready = append(ready, s)
+			}
+		}
+		if len(ready) == 0 {
+			return fmt.Errorf("pipeline %s: no runnable step", p.Name)
+		}
+
+		errs := make([]error, len(ready))
+		var wg sync.WaitGroup
+		for i, s := range ready {
+			wg.Add(1)
+			go func(i int, s Step) {
+				defer wg.Done()
+				errs[i] = run.execStep(ctx, s)
+			}(i, s)
+		}
+		wg.Wait()
+
+		var failed error
+		for i, s := range ready {
+			if errs[i] != nil && failed == nil {
+				failed = fmt.Errorf("step %s: %w", s.Name, errs[i])
+			}
+			done[s.Name] = true
+		}
+		if failed != nil {
+			if ctx.Err() != nil {
+				return run.interrupted(ctx)
+			}
+			run.compensate(ctx)
+			return failed
+		}
+	}
+	return nil
+}
+
+// interrupted 处理任务 context 被取消: 暂停时保留已完成步骤, 取消时补偿已完成步骤
+// 租约丢失时任务已由其他实例接管, 直接退出
+func (run *pipelineRun) interrupted(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, errTaskPaused) || errors.Is(cause, errLeaseLost) {
+		return cause
+	}
+
+	// 恢复执行前已完成的步骤本次未执行, 一并补偿
+	run.mu.Lock()
+	for _, s := range run.p.steps {
+		if run.task.Step(s.Name).Status == model.StepSucceeded && !run.wasExecuted(s.Name) {
+			run.executed = append(run.executed, s)
+		}
+	}
+	run.mu.Unlock()
+	run.compensate(ctx)
+
+	if errors.Is(cause, errTaskCancelled) {
+		return errTaskCancelled
+	}
+	return cause
+}
+
+func (run *pipelineRun) wasExecuted(name string) bool {
+	for _, s := range run.executed {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// initSteps 预先写入全部步骤记录, 便于查询任务时看到完整流程
+func (run *pipelineRun) initSteps() {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	for _, s := range run.p.steps {
+		if run.task.Step(s.Name) == nil {
+			run.task.Steps = append(run.task.Steps, model.TaskStep{
+				Name:   s.Name,
+				Status: model.StepPending,
+			})
+		}
+	}
+	run.save()
+}
+
+func (run *pipelineRun) depsDone(s Step, done map[string]bool) bool {
+	for _, dep := range s.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// save 持久化任务检查点, 调用方需持有锁
+func (run *pipelineRun) save() {
+	_ = run.wf.tasks.Save(context.Background(), run.task)
+}
+
+// updateStep 在锁内修改步骤记录并写检查点
+func (run *pipelineRun) updateStep(name string, fn func(step *model.TaskStep)) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	fn(run.task.Step(name))
+	run.save()
+}
+
+// execStep 按重试策略执行单个步骤, 已成功且幂等键相同时跳过
+func (run *pipelineRun) execStep(ctx context.Context, s Step) error {
+	key := run.task.ID + "/" + s.Name
+	if s.IdempotencyKey != nil {
+		key = s.IdempotencyKey(run.task)
+	}
+
+	run.mu.Lock()
+	record := run.task.Step(s.Name)
+	skip := record.Status == model.StepSucceeded && record.IdempotencyKey == key
+	run.executed = append(run.executed, s)
+	run.mu.Unlock()
+	if skip {
+		run.rlog.Info().Str("step", s.Name).Msg("Step already succeeded, skipped")
+		return nil
+	}
+
+	sc := &StepContext{task: run.task, mu: &run.mu, key: key}
+	maxAttempts := s.Retry.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.Retry.backoff(attempt - 1)):
+			}
+		}
+
+		run.updateStep(s.Name, func(step *model.TaskStep) {
+			step.Status = model.StepRunning
+			step.IdempotencyKey = key
+			step.Attempts++
+			step.StartedAt = time.Now()
+			step.Error = ""
+		})
+
+		err = run.attempt(ctx, s, sc)
+
+		paused := err != nil && errors.Is(context.Cause(ctx), errTaskPaused)
+		run.updateStep(s.Name, func(step *model.TaskStep) {
+			step.FinishedAt = time.Now()
+			switch {
+			case err == nil:
+				step.Status = model.StepSucceeded
+			case paused:
+				// 暂停中断的步骤恢复后重新执行
+				step.Status = model.StepPending
+				step.Error = errTaskPaused.Error()
+			default:
+				step.Status = model.StepFailed
+				step.Error = err.Error()
+			}
+		})
+		if err == nil {
+			return nil
+		}
+
+		run.rlog.Warn().Err(err).Str("step", s.Name).Int("attempt", attempt+1).Msg("Step attempt failed")
+		if ctx.Err() != nil || (s.Retry.RetryIf != nil && !s.Retry.RetryIf(err)) {
+			break
+		}
+	}
+	return err
+}
+
+// compensate 按执行的逆序补偿, 每次尝试记录到任务历史
+// 任务上下文可能已被取消, 补偿使用独立的上下文; 重试等待期间 worker 退出或租约丢失时停止补偿
+func (run *pipelineRun) compensate(ctx context.Context) {
+	parent := ctx
+	ctx = context.WithoutCancel(ctx)
+	for i := len(run.executed) - 1; i >= 0; i-- {
+		s := run.executed[i]
+		if s.Compensate == nil {
+			continue
+		}
+		policy := s.CompensateRetry
+		if policy.MaxAttempts < 1 {
+			policy = defaultCompensateRetry
+		}
+
+		sc := &StepContext{task: run.task, mu: &run.mu, key: run.task.Step(s.Name).IdempotencyKey}
+		var err error
+		for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+			if attempt > 0 && !compensateWait(parent, policy.backoff(attempt-1)) {
+				run.rlog.Warn().Err(context.Cause(parent)).Str("step", s.Name).Msg("Compensation interrupted")
+				return
+			}
+			record := model.CompensationRecord{Step: s.Name, Attempt: attempt + 1, StartedAt: time.Now()}
+
+			attemptCtx, cancel := context.WithTimeout(ctx, compensateTimeout)
+			err = s.Compensate(attemptCtx, sc)
+			cancel()
+
+			record.FinishedAt = time.Now()
+			record.Status = model.StepCompensated
+			if err != nil {
+				record.Status = model.StepCompensationFailed
+				record.Error = err.Error()
+			}
+			run.mu.Lock()
+			run.task.Compensations = append(run.task.Compensations, record)
+			run.task.Step(s.Name).Status = record.Status
+			run.save()
+			run.mu.Unlock()
+
+			if err == nil {
+				break
+			}
+			run.rlog.Warn().Err(err).Str("step", s.Name).Int("attempt", attempt+1).Msg("Compensation attempt failed")
+		}
+		if err != nil {
+			run.rlog.Error().Err(err).Str("step", s.Name).Msg("Compensation failed, manual cleanup required")
+		}
+	}
+}
+
+// compensateWait 补偿重试前等待 d, ctx 因 worker 退出或租约丢失结束时返回 false
+// 任务取消、暂停不打断补偿
+func compensateWait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	done := ctx.Done()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-done:
+			cause := context.Cause(ctx)
+			if errors.Is(cause, errTaskCancelled) || errors.Is(cause, errTaskPaused) {
+				done = nil
+				continue
+			}
+			return false
+		}
+	}
+}
+
+// attempt 带超时执行一次步骤
+func (run *pipelineRun) attempt(ctx context.Context, s Step, sc *StepContext) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	return s.Run(ctx, sc)
+}
+
+// backoff 第 n 次重试前的等待时间(指数退避)
+func (rp RetryPolicy) backoff(n int) time.Duration {
+	d := float64(rp.Backoff) * math.Pow(2, float64(n))
+	if rp.MaxBackoff > 0 && d > float64(rp.MaxBackoff) {
+		d = float64(rp.MaxBackoff)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"centralHub/logger"
+	"centralHub/model"
+	"centralHub/store"
+)
+
+// ErrIdempotencyKeyReused 幂等键已用于其他域名或其他类型任务的提交
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used for a different request")
+
+// ErrOwnershipNotVerified 所有者尚未验证域名所有权, 或验证已失效
+var ErrOwnershipNotVerified = errors.New("domain ownership not verified")
+
+// ErrDomainOverlap 域名与其他用户的域名重叠(同名, 或泛域名与其下的普通域名)
+var ErrDomainOverlap = errors.New("domain overlaps with a domain owned by another user")
+
+// 视为重复提交的已有任务状态: 进行中或已成功完成
+var duplicateStates = []model.TaskState{
+	model.TaskPending, model.TaskRunning, model.TaskPaused, model.TaskSucceeded,
+}
+
+// SubmitOptions 提交任务的去重选项及提交者信息
+type SubmitOptions struct {
+	IdempotencyKey string
+	Policy         model.DuplicatePolicy // 为空时使用配置的默认策略
+	Actor          string
+	RequestID      string
+	SourceRevision int    // 回滚的来源版本
+	Patch          []byte // update_domain 任务的 JSON Merge Patch
+}
+
+// SubmitResult 提交结果
+type SubmitResult struct {
+	Task    *model.Task
+	Outcome string // model.OutcomeXXX
+	Related string // 排队等待或被覆盖的已有任务ID
+}
+
+/*
+SubmitTask 带去重的任务提交
+
+	1, 幂等键已存在: 返回该键对应的任务
+	2, 同一域名已有进行中或已完成的同类任务, 按策略处理; 创建任务完成后域名已删除的不算重复
+		return_existing: 返回已有任务
+		queue: 新建任务, 排在已有任务之后执行
+		supersede: 取消进行中的已有任务, 新任务在其补偿结束后执行
+	3, 否则新建任务
+
+创建及更新任务提交前规范化并校验域名配置(泛域名统一为 *.example.com), 创建时与其他用户的域名重叠返回 ErrDomainOverlap
+新建任务时域名状态不允许该操作返回 ErrInvalidDomainState, 创建时所有者未验证域名所有权返回 ErrOwnershipNotVerified
+*/
+func (wf *Workflow) SubmitTask(ctx context.Context, taskType string, input model.XLDomain, opts SubmitOptions) (*SubmitResult, error) {
+	policy := opts.Policy
+	if policy == "" {
+		policy = model.DuplicatePolicy(wf.cfg.DuplicatePolicy)
+	}
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("invalid duplicate policy: %s", policy)
+	}
+
+	// 只校验新提交的配置; 删除、启停及停用使用已保存的配置, 旧配置不再通过校验时仍可执行
+	if taskType == model.TaskTypeCreateDomain || taskType == model.TaskTypeUpdateDomain {
+		if err := input.Normalize(); err != nil {
+			return nil, err
+		}
+		if err := input.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	// cname 由 make_cname 步骤分配
+	input.CNAME = ""
+
+	if opts.IdempotencyKey != "" {
+		res, err := wf.findByIdempotencyKey(ctx, opts.IdempotencyKey, taskType, input)
+		if err != nil || res != nil {
+			return res, err
+		}
+	}
+
+	if taskType == model.TaskTypeCreateDomain {
+		if err := wf.checkOverlap(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+
+	task, err := wf.newTask(taskType, input)
+	if err != nil {
+		return nil, err
+	}
+	task.IdempotencyKey = opts.IdempotencyKey
+	task.Actor = opts.Actor
+	task.RequestID = opts.RequestID
+	task.SourceRevision = opts.SourceRevision
+	task.Patch = string(opts.Patch)
+	result := &SubmitResult{Task: task, Outcome: model.OutcomeCreated}
+
+	existing, err := wf.tasks.FindLatestByDomain(ctx, taskType, input.Name, duplicateStates)
+	if err != nil {
+		return nil, err
+	}
+	var cur *model.XLDomain
+	if taskType == model.TaskTypeCreateDomain {
+		if cur, err = wf.domains.FindByName(ctx, input.Name); err != nil {
+			return nil, err
+		}
+		// 已成功的创建任务之后域名已删除或部署失败, 可以重新创建, 不再视为重复提交
+		if existing != nil && existing.State.IsTerminal() && checkDomainOp(cur, model.DomainOpCreate) == nil {
+			existing = nil
+		}
+	}
+	if existing != nil {
+		switch policy {
+		case model.DuplicateReturnExisting:
+			return &SubmitResult{Task: existing, Outcome: model.OutcomeExisting}, nil
+		case model.DuplicateQueue:
+			result.Related = existing.ID
+			if !existing.State.IsTerminal() {
+				task.WaitFor = existing.ID
+				result.Outcome = model.OutcomeQueued
+			}
+		case model.DuplicateSupersede:
+			result.Related = existing.ID
+			result.Outcome = model.OutcomeSuperseded
+			task.Supersedes = existing.ID
+			if !existing.State.IsTerminal() {
+				// 等待被覆盖任务补偿结束, 避免与其并发操作 vendor
+				task.WaitFor = existing.ID
+			}
+		}
+	}
+
+	// 已有域名记录时按其状态判断能否重新创建, 如已删除或部署失败
+	if taskType == model.TaskTypeCreateDomain {
+		if err := checkDomainOp(cur, model.DomainOpCreate); err != nil {
+			return nil, err
+		}
+		if err := wf.checkOwnership(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := wf.tasks.Insert(ctx, *task); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) && opts.IdempotencyKey != "" {
+			// 并发的相同幂等键提交, 返回先写入的任务
+			res, ferr := wf.findByIdempotencyKey(ctx, opts.IdempotencyKey, taskType, input)
+			if ferr == nil && res != nil {
+				return res, nil
+			}
+		}
+		return nil, fmt.Errorf("save task failed: %w", err)
+	}
+
+	if task.Supersedes != "" {
+		_ = wf.tasks.SetSupersededBy(ctx, existing.ID, task.ID)
+		if !existing.State.IsTerminal() {
+			if err := wf.CancelTask(ctx, existing.ID); err != nil && !errors.Is(err, ErrInvalidTaskState) {
+				logger.RunLogger.Error().Err(err).Str("task_id", existing.ID).Msg("Cancel superseded task failed")
+			}
+		}
+	}
+
+	wf.enqueue(task.ID)
+	return result, nil
+}
+
+// checkOverlap 域名与其他用户未删除的域名重叠时返回 ErrDomainOverlap
+func (wf *Workflow) checkOverlap(ctx context.Context, input model.XLDomain) error {
+	overlap, err := wf.domains.FindOverlapping(ctx, input)
+	if err != nil {
+		return err
+	}
+	if overlap != nil {
+		return fmt.Errorf("%w: %s", ErrDomainOverlap, overlap.Name)
+	}
+	return nil
+}
+
+// checkOwnership 所有者是否已验证域名所有权, 泛域名按去掉 "*." 后的域名验证
+func (wf *Workflow) checkOwnership(ctx context.Context, d model.XLDomain) error {
+	verified, err := wf.ownership.Verified(ctx, d.BaseName(), d.Owner)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("%w: %s (owner %s)", ErrOwnershipNotVerified, d.BaseName(), d.Owner)
+	}
+	return nil
+}
+
+// findByIdempotencyKey 幂等键对应的已有任务, 同一键用于不同域名或不同类型的任务时报错
+func (wf *Workflow) findByIdempotencyKey(ctx context.Context, key, taskType string, input model.XLDomain) (*SubmitResult, error) {
+	task, err := wf.tasks.FindByIdempotencyKey(ctx, key)
+	if err != nil || task == nil {
+		return nil, err
+	}
+	if task.Domain != input.Name || task.Type != taskType {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return &SubmitResult{Task: task, Outcome: model.OutcomeExisting}, nil
+}
@@ -0,0 +1,142 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"centralHub/config"
+	"centralHub/model"
+)
+
+// ErrNoVendorAvailable 没有满足条件的 vendor, 重试无意义
+var ErrNoVendorAvailable = errors.New("no vendor available")
+
+// VendorPolicy vendor 选择策略
+type VendorPolicy struct {
+	cfg     config.VendorPolicyConfig
+	vendors *VendorRegistry
+}
+
+func NewVendorPolicy(cfg config.VendorPolicyConfig, vendors *VendorRegistry) *VendorPolicy {
+	return &VendorPolicy{cfg: cfg, vendors: vendors}
+}
+
+/*
+Select 为域名选择 vendor, 返回每个 vendor 的选择结果及原因
+
+	1, 停用的 vendor 不参与
+	2, 租户规则: allow 列表之外、deny 列表之内的 vendor 排除; 域名指定了 vendors 时只用指定的
+	3, 服务区域: vendor 需覆盖域名的服务区域
+	4, 备案: 明确未备案的域名不能使用大陆区域
+	5, 功能: 泛域名需要 wildcard, 以及域名要求或配置隐含的 https/http2/quic/ipv6
+	6, 健康: 连续失败的 vendor 在冷却时间内排除
+	7, 按权重从高到低选取 max_vendors 个, 权重相同按名称
+*/
+func (vp *VendorPolicy) Select(obj model.XLDomain) []model.VendorDecision {
+	var decisions []model.VendorDecision
+	var candidates []*Vendor
+	for _, v := range vp.vendors.All() {
+		if reason := vp.exclude(v, obj); reason != "" {
+			decisions = append(decisions, model.VendorDecision{Vendor: v.Name, Weight: v.Weight, Reason: reason})
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Weight > candidates[j].Weight
+	})
+	for i, v := range candidates {
+		d := model.VendorDecision{Vendor: v.Name, Weight: v.Weight}
+		if i < vp.cfg.MaxVendors {
+			d.Selected = true
+			d.Reason = fmt.Sprintf("selected: rank %d by weight", i+1)
+		} else {
+			d.Reason = fmt.Sprintf("eligible but max_vendors %d reached", vp.cfg.MaxVendors)
+		}
+		decisions = append(decisions, d)
+	}
+
+	// 选中的排在前面, 便于查看
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].Selected && !decisions[j].Selected
+	})
+	return decisions
+}
+
+// exclude 返回 vendor 不可用于该域名的原因, 可用时返回空串
+func (vp *VendorPolicy) exclude(v *Vendor, obj model.XLDomain) string {
+	if !v.Enabled {
+		return "disabled"
+	}
+	if rule := vp.tenantRule(obj.Owner); rule != nil {
+		if len(rule.Allow) > 0 && !contains(rule.Allow, v.Name) {
+			return fmt.Sprintf("not allowed for owner %s", obj.Owner)
+		}
+		if contains(rule.Deny, v.Name) {
+			return fmt.Sprintf("denied for owner %s", obj.Owner)
+		}
+	}
+	if len(obj.Vendors) > 0 && !contains(obj.Vendors, v.Name) {
+		return "not requested by domain"
+	}
+	region := obj.ServiceRegion()
+	if !v.Covers(region) {
+		return fmt.Sprintf("region %s not covered", region)
+	}
+	if region != model.RegionOverseas && obj.ICPStatus == model.ICPNone {
+		return fmt.Sprintf("region %s requires ICP filing", region)
+	}
+	if obj.IsWildcard() && !v.Supports(model.CapabilityWildcard) {
+		return "wildcard domain not supported"
+	}
+	var missing []string
+	for _, c := range obj.RequiredCapabilities() {
+		if c != model.CapabilityWildcard && !v.Supports(c) {
+			missing = append(missing, string(c))
+		}
+	}
+	if len(missing) > 0 {
+		return "missing capability: " + strings.Join(missing, ",")
+	}
+	if healthy, failures := v.Healthy(); !healthy {
+		return fmt.Sprintf("unhealthy: %d consecutive failures", failures)
+	}
+	return ""
+}
+
+func (vp *VendorPolicy) tenantRule(owner string) *config.TenantVendorRule {
+	for i := range vp.cfg.Tenants {
+		if vp.cfg.Tenants[i].Owner == owner {
+			return &vp.cfg.Tenants[i]
+		}
+	}
+	return nil
+}
+
+// SelectedVendors 选中的 vendor 名称
+func SelectedVendors(decisions []model.VendorDecision) []string {
+	var names []string
+	for _, d := range decisions {
+		if d.Selected {
+			names = append(names, d.Vendor)
+		}
+	}
+	return names
+}
+
+// PlanVendors 预览域名会落在哪些 vendor 上, 不创建任务
+func (wf *Workflow) PlanVendors(obj model.XLDomain) []model.VendorDecision {
+	return wf.vendorPolicy.Select(obj)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"centralHub/client"
+	"centralHub/config"
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+// VendorFactory 按配置创建 vendor 客户端
+type VendorFactory func(cfg config.VendorConfig) (VendorClient, error)
+
+var (
+	vendorFactories   = make(map[string]VendorFactory)
+	vendorFactoriesMu sync.RWMutex
+)
+
+// RegisterVendorFactory 注册 vendor 类型, 新增 CDN 厂商时在 init 中调用
+func RegisterVendorFactory(vendorType string, factory VendorFactory) {
+	vendorFactoriesMu.Lock()
+	defer vendorFactoriesMu.Unlock()
+	if _, ok := vendorFactories[vendorType]; ok {
+		panic(fmt.Sprintf("vendor factory %s already registered", vendorType))
+	}
+	vendorFactories[vendorType] = factory
+}
+
+func getVendorFactory(vendorType string) VendorFactory {
+	vendorFactoriesMu.RLock()
+	defer vendorFactoriesMu.RUnlock()
+	return vendorFactories[vendorType]
+}
+
+func init() {
+	RegisterVendorFactory("mock", func(cfg config.VendorConfig) (VendorClient, error) {
+		return client.NewMockClient(cfg.Name), nil
+	})
+	RegisterVendorFactory("volcengine", func(cfg config.VendorConfig) (VendorClient, error) {
+		if cfg.AccessKey == "" || cfg.SecretKey == "" {
+			return nil, fmt.Errorf("access_key and secret_key are required")
+		}
+		return client.NewVolcClient(cfg.Name, config.VolcengineConfig{
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			Region:    cfg.Region,
+			Endpoint:  cfg.Endpoint,
+			Scheme:    cfg.Scheme,
+			Timeout:   cfg.Timeout,
+		}), nil
+	})
+}
+
+// vendor 健康判断: 连续失败达到阈值后在冷却时间内视为不健康
+// 状态只保存在当前进程内, 各副本分别统计且重启后清零, 仅作为选择 vendor 时的启发式排除,
+// 不作为 vendor 是否可用的依据; 真正的失败由各步骤的重试和成功数要求处理
+const (
+	vendorUnhealthyThreshold = 3
+	vendorUnhealthyCooldown  = 5 * time.Minute
+)
+
+// Vendor 已配置的 vendor 实例
+type Vendor struct {
+	Name         string
+	Enabled      bool
+	Weight       int
+	Capabilities map[model.VendorCapability]bool
+	Regions      map[string]bool // 为空表示覆盖全部区域
+	Client       VendorClient
+	Config       config.VendorConfig
+
+	mu             sync.Mutex
+	failures       int // 连续失败次数
+	unhealthyUntil time.Time
+}
+
+// Supports 是否支持指定功能
+func (v *Vendor) Supports(c model.VendorCapability) bool {
+	return v.Capabilities[c]
+}
+
+// Covers 是否覆盖服务区域, global 需要同时覆盖大陆和海外
+func (v *Vendor) Covers(region string) bool {
+	if len(v.Regions) == 0 || v.Regions[model.RegionGlobal] {
+		return true
+	}
+	if region == model.RegionGlobal {
+		return v.Regions[model.RegionMainland] && v.Regions[model.RegionOverseas]
+	}
+	return v.Regions[region]
+}
+
+// ReportResult 记录调用结果; 只有 vendor 不可用类的错误计入失败
+func (v *Vendor) ReportResult(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err == nil {
+		v.failures = 0
+		v.unhealthyUntil = time.Time{}
+		return
+	}
+	if !client.IsKind(err, client.ErrKindUnavailable) && !client.IsKind(err, client.ErrKindRateLimited) {
+		return
+	}
+	v.failures++
+	if v.failures >= vendorUnhealthyThreshold {
+		v.unhealthyUntil = time.Now().Add(vendorUnhealthyCooldown)
+	}
+}
+
+// Healthy 是否健康, 冷却结束后重新尝试
+func (v *Vendor) Healthy() (bool, int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if time.Now().Before(v.unhealthyUntil) {
+		return false, v.failures
+	}
+	return true, v.failures
+}
+
+// VendorRegistry 按配置创建的 vendor 实例
+// 停用的 vendor 仍会创建客户端, 以便补偿、删除其上已有的域名, 但不会被选中
+type VendorRegistry struct {
+	vendors map[string]*Vendor
+	names   []string // 按名称排序, 保证选择结果稳定
+}
+
+// NewVendorRegistry 按配置创建 vendor 实例
+func NewVendorRegistry(cfgs []config.VendorConfig) (*VendorRegistry, error) {
+	reg := &VendorRegistry{vendors: make(map[string]*Vendor)}
+	for _, cfg := range cfgs {
+		if _, ok := reg.vendors[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate vendor: %s", cfg.Name)
+		}
+		factory := getVendorFactory(cfg.Type)
+		if factory == nil {
+			return nil, fmt.Errorf("vendor %s: unknown type %s", cfg.Name, cfg.Type)
+		}
+		caps := make(map[model.VendorCapability]bool, len(cfg.Capabilities))
+		for _, c := range cfg.Capabilities {
+			capability := model.VendorCapability(c)
+			if !capability.IsValid() {
+				return nil, fmt.Errorf("vendor %s: unknown capability %s", cfg.Name, c)
+			}
+			caps[capability] = true
+		}
+		regions := make(map[string]bool, len(cfg.Regions))
+		for _, r := range cfg.Regions {
+			if !model.IsValidRegion(r) {
+				return nil, fmt.Errorf("vendor %s: unknown region %s", cfg.Name, r)
+			}
+			regions[r] = true
+		}
+		clt, err := factory(cfg)
+		if err != nil {
+			if cfg.Enabled {
+				return nil, fmt.Errorf("vendor %s: %w", cfg.Name, err)
+			}
+			logger.RunLogger.Warn().Err(err).Str("vendor", cfg.Name).Msg("Skip disabled vendor")
+			continue
+		}
+		reg.vendors[cfg.Name] = &Vendor{
+			Name:         cfg.Name,
+			Enabled:      cfg.Enabled,
+			Weight:       cfg.Weight,
+			Capabilities: caps,
+			Regions:      regions,
+			Client:       clt,
+			Config:       cfg,
+		}
+		reg.names = append(reg.names, cfg.Name)
+	}
+	sort.Strings(reg.names)
+	return reg, nil
+}
+
+// Get 返回 vendor, 未配置时返回 nil
+func (r *VendorRegistry) Get(name string) *Vendor {
+	return r.vendors[name]
+}
+
+// Enabled 返回启用的 vendor
+func (r *VendorRegistry) Enabled() []*Vendor {
+	var vendors []*Vendor
+	for _, name := range r.names {
+		if v := r.vendors[name]; v.Enabled {
+			vendors = append(vendors, v)
+		}
+	}
+	return vendors
+}
+
+// All 返回全部已配置的 vendor
+func (r *VendorRegistry) All() []*Vendor {
+	vendors := make([]*Vendor, 0, len(r.names))
+	for _, name := range r.names {
+		vendors = append(vendors, r.vendors[name])
+	}
+	return vendors
+}
@@ -0,0 +1,252 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"centralHub/client"
+	"centralHub/logger"
+	"centralHub/model"
+)
+
+var (
+	// ErrVendorNotFound 回调的 vendor 未配置
+	ErrVendorNotFound = errors.New("vendor not found")
+	// ErrInvalidCallback 回调内容无法解析
+	ErrInvalidCallback = errors.New("invalid callback payload")
+)
+
+const (
+	// 轮询间隔上限
+	vendorPollMaxInterval = time.Minute
+	// 检查回调事件的间隔, 用于接收其他副本收到的回调
+	vendorEventCheckInterval = 2 * time.Second
+)
+
+// CallbackParser vendor 自定义的回调验签及解析
+// 未实现时使用 client.VerifyCallbackSignature 校验并按 client.CallbackPayload 解析
+type CallbackParser interface {
+	ParseCallback(header http.Header, body []byte, secret string) (*model.VendorEvent, error)
+}
+
+// ReceiveVendorCallback 处理 vendor 回调: 验签, 保存事件, 唤醒本实例上等待的任务
+func (wf *Workflow) ReceiveVendorCallback(ctx context.Context, vendor string, header http.Header, body []byte) (*model.VendorEvent, error) {
+	v := wf.vendors.Get(vendor)
+	if v == nil {
+		return nil, ErrVendorNotFound
+	}
+
+	var event *model.VendorEvent
+	if parser, ok := v.Client.(CallbackParser); ok {
+		var err error
+		if event, err = parser.ParseCallback(header, body, v.Config.CallbackSecret); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := client.VerifyCallbackSignature(v.Config.CallbackSecret, header, body, time.Now()); err != nil {
+			return nil, err
+		}
+		var payload client.CallbackPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+		}
+		event = &model.VendorEvent{
+			Domain:  payload.Domain,
+			Status:  model.VendorDomainStatus(payload.Status),
+			CNAME:   payload.CNAME,
+			Message: payload.Message,
+		}
+	}
+	if event.Domain == "" || event.Status == "" {
+		return nil, fmt.Errorf("%w: domain and status are required", ErrInvalidCallback)
+	}
+
+	event.ID = uuid.New().String()
+	event.Vendor = vendor
+	event.ReceivedAt = time.Now()
+	if err := wf.events.Insert(ctx, *event); err != nil {
+		return nil, err
+	}
+	wf.notifyVendorEvent(vendor, event.Domain)
+	return event, nil
+}
+
+// vendorEventKey 等待回调的键
+func vendorEventKey(vendor, domain string) string {
+	return vendor + "/" + domain
+}
+
+// vendorEventWaiters 本实例上等待 vendor 回调的任务
+type vendorEventWaiters struct {
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+// watchVendorEvent 注册等待, 返回的 cancel 需在等待结束后调用
+func (wf *Workflow) watchVendorEvent(vendor, domain string) (<-chan struct{}, func()) {
+	w := &wf.eventWaiters
+	key := vendorEventKey(vendor, domain)
+	ch := make(chan struct{}, 1)
+
+	w.mu.Lock()
+	if w.waiters == nil {
+		w.waiters = make(map[string]map[chan struct{}]struct{})
+	}
+	if w.waiters[key] == nil {
+		w.waiters[key] = make(map[chan struct{}]struct{})
+	}
+	w.waiters[key][ch] = struct{}{}
+	w.mu.Unlock()
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.waiters[key], ch)
+		if len(w.waiters[key]) == 0 {
+			delete(w.waiters, key)
+		}
+	}
+}
+
+func (wf *Workflow) notifyVendorEvent(vendor, domain string) {
+	w := &wf.eventWaiters
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.waiters[vendorEventKey(vendor, domain)] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+/*
+waitVendorsOnline 等待已创建的 vendor 域名上线
+vendor 侧配置下发是异步的, 每个 vendor 并发等待:
+
+	1, 按退避间隔轮询 vendor 域名状态
+	2, 收到回调时立即推进, 其他副本收到的回调通过事件表定期检查
+	3, 上线或失败即结束, 超时由步骤 Timeout 控制
+
+返回更新后的结果, 上线的 vendor 数未达到 quorum 时返回 *QuorumError
+*/
+func (wf *Workflow) waitVendorsOnline(ctx context.Context, domain string, prev []model.VendorResult) ([]model.VendorResult, error) {
+	results := append([]model.VendorResult(nil), prev...)
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	for i := range results {
+		r := &results[i]
+		if !r.Success || r.Status.IsTerminal() {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r *model.VendorResult) {
+			defer wg.Done()
+
+			start := time.Now()
+			status, cname, err := wf.waitVendorOnline(ctx, r.Vendor, domain, r.UpdatedAt)
+			r.LatencyMs += time.Since(start).Milliseconds()
+			r.UpdatedAt = time.Now()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			r.Status = status
+			if cname != "" {
+				r.CNAME = cname
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// 暂停/取消中断, 保留原结果, 恢复后重新等待
+		return prev, ctx.Err()
+	}
+
+	online := 0
+	for i := range results {
+		r := &results[i]
+		switch {
+		case errs[i] != nil:
+			r.Success = false
+			r.ErrorKind = string(client.KindOf(errs[i]))
+			r.Error = errs[i].Error()
+		case r.Success && r.Status == model.VendorDomainFailed:
+			r.Success = false
+			r.Error = "vendor domain configuration failed"
+			errs[i] = fmt.Errorf("vendor %s: domain configuration failed", r.Vendor)
+		case r.Success:
+			online++
+		}
+	}
+	required := requiredSuccesses(wf.vendorPolicy.cfg.Quorum, len(results))
+	if online < required {
+		return results, &QuorumError{Succeeded: online, Required: required, Total: len(results), Err: errors.Join(errs...)}
+	}
+	return results, nil
+}
+
+// waitVendorOnline 等待单个 vendor 上的域名进入终态, 返回状态及 cname
+// since 之前收到的回调事件不予采用
+func (wf *Workflow) waitVendorOnline(ctx context.Context, vendor, domain string, since time.Time) (model.VendorDomainStatus, string, error) {
+	v := wf.vendors.Get(vendor)
+	if v == nil {
+		return "", "", fmt.Errorf("vendor %s: client not registered", vendor)
+	}
+	rlog := logger.RunLogger.With().Str("vendor", vendor).Str("domain", domain).Logger()
+
+	wake, cancel := wf.watchVendorEvent(vendor, domain)
+	defer cancel()
+	eventTicker := time.NewTicker(vendorEventCheckInterval)
+	defer eventTicker.Stop()
+	pollTimer := time.NewTimer(0)
+	defer pollTimer.Stop()
+	interval := time.Duration(wf.cfg.VendorPollInterval) * time.Second
+
+	checkEvent := func() (*model.VendorEvent, bool) {
+		event, err := wf.events.FindLatest(ctx, vendor, domain, since)
+		if err != nil || event == nil || !event.Status.IsTerminal() {
+			return nil, false
+		}
+		return event, true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		case <-wake:
+			if event, ok := checkEvent(); ok {
+				rlog.Info().Str("status", string(event.Status)).Msg("Vendor domain status pushed by callback")
+				return event.Status, event.CNAME, nil
+			}
+		case <-eventTicker.C:
+			if event, ok := checkEvent(); ok {
+				rlog.Info().Str("status", string(event.Status)).Msg("Vendor domain status pushed by callback")
+				return event.Status, event.CNAME, nil
+			}
+		case <-pollTimer.C:
+			d, err := v.Client.GetDomain(ctx, domain)
+			if ctx.Err() != nil {
+				return "", "", ctx.Err()
+			}
+			v.ReportResult(err)
+			if err != nil && !client.IsRetryable(err) {
+				return "", "", err
+			}
+			if err == nil && d.Status.IsTerminal() {
+				rlog.Info().Str("status", string(d.Status)).Msg("Vendor domain status polled")
+				return d.Status, d.CNAME, nil
+			}
+			pollTimer.Reset(interval)
+			interval = min(interval*2, vendorPollMaxInterval)
+		}
+	}
+}
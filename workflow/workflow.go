@@ -1,37 +1,113 @@
 package workflow
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+
 	"centralHub/client"
+	"centralHub/config"
+	"centralHub/model"
+	"centralHub/service"
+	"centralHub/store"
 )
 
+// VendorClient CDN vendor 的域名生命周期接口
+// 错误返回 *client.VendorError, 按 client.ErrKind 区分处理
 type VendorClient interface {
-	// 定义 VendorClient 接口的方法
-	GetVendorName(params ...interface{}) string
-	CreateDomain(params ...interface{}) error
+	Name() string
+	// CreateDomain 创建域名, 已存在时返回 ErrKindConflict
+	CreateDomain(ctx context.Context, req model.VendorDomainRequest) (*model.VendorDomain, error)
+	GetDomain(ctx context.Context, domain string) (*model.VendorDomain, error)
+	UpdateDomainConfig(ctx context.Context, req model.VendorDomainRequest) error
+	// DeleteDomain 删除域名, 不存在时返回 ErrKindNotFound
+	DeleteDomain(ctx context.Context, domain string) error
+	EnableDomain(ctx context.Context, domain string) error
+	DisableDomain(ctx context.Context, domain string) error
+	Purge(ctx context.Context, req model.PurgeRequest) (*model.VendorTaskResult, error)
+	Prefetch(ctx context.Context, req model.PrefetchRequest) (*model.VendorTaskResult, error)
+	GetCNAME(ctx context.Context, domain string) (string, error)
+	GetStatus(ctx context.Context, domain string) (model.VendorDomainStatus, error)
 }
 
 type Workflow struct {
-	vendorClients map[string]VendorClient
+	vendors      *VendorRegistry
+	vendorPolicy *VendorPolicy
+	dnsClient    *client.DNSClient
+	cnames       *service.CNAMEService
+	ownership    *service.OwnershipService
+
+	tasks     *store.TaskStore
+	domains   *store.DomainStore
+	revisions *store.RevisionStore
+	locks     *store.LockStore
+	events    *store.VendorEventStore
+	handlers  map[string]TaskHandler
+	queue     chan string
+	cfg       config.WorkflowConfig
+
+	// 本进程正在执行的任务, 用于取消/暂停
+	running   map[string]context.CancelCauseFunc
+	runningMu sync.Mutex
+	// 实例标识, 作为任务租约持有者
+	instanceID string
+	// 等待 vendor 回调的任务
+	eventWaiters vendorEventWaiters
 }
 
-func NewWorkflow() *Workflow {
-	cltDict := make(map[string]VendorClient)
-	cltDict["mock-vendor"] = client.NewMockClient()
-	return &Workflow{
-		vendorClients: cltDict,
+func NewWorkflow(db *mongo.Database, cfg *config.Config) (*Workflow, error) {
+	vendors, err := NewVendorRegistry(cfg.Vendors)
+	if err != nil {
+		return nil, fmt.Errorf("init vendors failed: %w", err)
+	}
+	wf := &Workflow{
+		vendors:      vendors,
+		vendorPolicy: NewVendorPolicy(cfg.VendorPolicy, vendors),
+		dnsClient:    client.NewDNSClient(),
+		cnames:       service.NewCNAMEService(store.NewCNAMEStore(db), cfg.CNAME),
+		ownership:    service.NewOwnershipService(store.NewChallengeStore(db), cfg.Ownership),
+		tasks:        store.NewTaskStore(db),
+		domains:      store.NewDomainStore(db),
+		revisions:    store.NewRevisionStore(db),
+		locks:        store.NewLockStore(db),
+		events:       store.NewVendorEventStore(db),
+		handlers:     make(map[string]TaskHandler),
+		queue:        make(chan string, cfg.Workflow.QueueSize),
+		cfg:          cfg.Workflow,
+		running:      make(map[string]context.CancelCauseFunc),
+		instanceID:   newInstanceID(),
 	}
+	wf.RegisterPipeline(model.TaskTypeCreateDomain, wf.CreateDomain())
+	wf.RegisterPipeline(model.TaskTypeUpdateDomain, wf.UpdateDomain())
+	wf.RegisterPipeline(model.TaskTypeDeleteDomain, wf.DeleteDomain())
+	wf.RegisterPipeline(model.TaskTypeEnableDomain, wf.ToggleDomain(true))
+	wf.RegisterPipeline(model.TaskTypeDisableDomain, wf.ToggleDomain(false))
+	wf.RegisterPipeline(model.TaskTypeSuspendDomain, wf.SuspendDomain())
+	return wf, nil
 }
 
-// 待后续集成 https://github.com/ZebraKK/workflow
-func (ws *Workflow) PushTask() string {
-
-	return "task-12345"
+// Ownership 所有权验证服务, 创建域名前要求已验证
+func (wf *Workflow) Ownership() *service.OwnershipService {
+	return wf.ownership
 }
 
 func (wf *Workflow) getVendorClient(vendor string) VendorClient {
-	clt, ok := wf.vendorClients[vendor]
-	if !ok {
+	v := wf.vendors.Get(vendor)
+	if v == nil {
 		return nil
 	}
-	return clt
+	return v.Client
+}
+
+// newInstanceID 主机名加随机后缀, 同一主机的多个进程也不会冲突
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "centralhub"
+	}
+	return host + "-" + uuid.New().String()[:8]
 }